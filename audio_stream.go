@@ -0,0 +1,241 @@
+package houndify
+
+import (
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// channelReader adapts a channel of byte slices to an io.Reader
+type channelReader struct {
+	ch  <-chan []byte
+	buf []byte
+}
+
+// ChannelReader returns an io.Reader that reads the byte slices sent on ch in order,
+// suitable for use as a VoiceRequest's AudioStream. The reader returns io.EOF once ch
+// is closed and all of the data sent on it has been read.
+func ChannelReader(ch <-chan []byte) io.Reader {
+	return &channelReader{ch: ch}
+}
+
+func (r *channelReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// wait for the next non empty chunk, or for the channel to close
+	for len(r.buf) == 0 {
+		chunk, ok := <-r.ch
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// BytesPerSecond returns the number of bytes in one second of uncompressed PCM audio
+// with the given format, e.g. for pacing a raw audio stream in real time.
+// 16kHz, 16-bit, mono audio is 32000 bytes per second.
+func BytesPerSecond(sampleRate, bitsPerSample, channels int) int {
+	return sampleRate * bitsPerSample * channels / 8
+}
+
+// pacedReader reads from r no faster than bytesPerSecond, like audio being recorded in
+// real time
+type pacedReader struct {
+	r              io.Reader
+	bytesPerSecond int
+	start          time.Time
+	read           int64
+}
+
+// newPacedReader returns a reader that reads from r no faster than bytesPerSecond.
+// If bytesPerSecond isn't positive, r is returned as is.
+func newPacedReader(r io.Reader, bytesPerSecond int) io.Reader {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+	return &pacedReader{r: r, bytesPerSecond: bytesPerSecond}
+}
+
+func (p *pacedReader) Read(buf []byte) (int, error) {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	// read in chunks of at most a tenth of a second of audio, so it's sent smoothly
+	if chunk := p.bytesPerSecond / 10; chunk > 0 && len(buf) > chunk {
+		buf = buf[:chunk]
+	}
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	// wait until it's time for the bytes read so far to have been recorded
+	due := p.start.Add(time.Duration(p.read) * time.Second / time.Duration(p.bytesPerSecond))
+	time.Sleep(time.Until(due))
+	return n, err
+}
+
+// commandAudioStream reads the stdout of a command, waiting for it to exit at the end
+type commandAudioStream struct {
+	io.Reader
+	cmd *exec.Cmd
+	// held while reading, as the command mustn't be waited for during a read of its stdout
+	mu   sync.Mutex
+	done bool
+	// the command is only killed and waited for once, by Read or Close
+	killOnce sync.Once
+	waitOnce sync.Once
+	waitErr  error
+}
+
+// NewCommandAudioStream starts cmd and returns a reader of its stdout, paced to
+// bytesPerSecond, suitable for use as a VoiceRequest's AudioStream. This is useful for
+// streaming audio captured by an external tool, such as arecord.
+//
+// Once stdout is exhausted the command is waited for, and if it failed its error is
+// returned instead of io.EOF. The returned reader also implements io.Closer, which
+// kills the command if it's still running, and may be called from another goroutine
+// than Read. VoiceSearch closes it when the search ends.
+func NewCommandAudioStream(cmd *exec.Cmd, bytesPerSecond int) (io.Reader, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandAudioStream{
+		Reader: newPacedReader(stdout, bytesPerSecond),
+		cmd:    cmd,
+	}, nil
+}
+
+func (s *commandAudioStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return 0, io.EOF
+	}
+	n, err := s.Reader.Read(p)
+	if err == io.EOF {
+		s.done = true
+		if waitErr := s.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close kills the command if it's still running, and waits for it to exit
+func (s *commandAudioStream) Close() error {
+	// killing the command ends any read in progress, so the lock can be taken
+	s.killOnce.Do(func() {
+		s.cmd.Process.Kill()
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.wait()
+	return nil
+}
+
+// wait waits for the command to exit, only the first time it's called
+func (s *commandAudioStream) wait() error {
+	s.waitOnce.Do(func() {
+		s.waitErr = s.cmd.Wait()
+	})
+	return s.waitErr
+}
+
+// silenceEndpointedReader reads 16-bit PCM audio until it ends with enough silence
+type silenceEndpointedReader struct {
+	r              io.Reader
+	threshold      int
+	frameSamples   int
+	silenceSamples int
+	// the low byte of a sample split between reads
+	low    byte
+	hasLow bool
+	// the sum of the amplitudes of the current frame, and how many samples it has so far
+	frameSum   int
+	frameCount int
+	// whether any speech has been heard, and how many silent samples have followed it
+	heardSpeech   bool
+	silentSamples int
+	done          bool
+}
+
+// The length of the frames whose loudness is compared against the silence threshold
+const silenceFrameDuration = 20 * time.Millisecond
+
+// NewSilenceEndpointedReader returns a reader of 16-bit, little endian, mono PCM audio
+// that ends once speech is followed by silenceDuration of silence, like the end of a
+// spoken query. The audio is silent while the mean amplitude over each 20ms frame is no
+// more than silenceThreshold, out of 32767, so short clicks and pops in the silence
+// don't count as speech. Leading silence, before any speech, doesn't end the audio.
+//
+// This complements the server's SafeToStopAudio, which may be unreliable in some
+// environments, e.g. when the audio is noisy.
+func NewSilenceEndpointedReader(pcm io.Reader, sampleRate int, silenceThreshold int, silenceDuration time.Duration) io.Reader {
+	frameSamples := int(silenceFrameDuration.Seconds() * float64(sampleRate))
+	if frameSamples < 1 {
+		frameSamples = 1
+	}
+	silenceSamples := int(silenceDuration.Seconds() * float64(sampleRate))
+	if silenceSamples < 1 {
+		silenceSamples = 1
+	}
+	return &silenceEndpointedReader{
+		r:              pcm,
+		threshold:      silenceThreshold,
+		frameSamples:   frameSamples,
+		silenceSamples: silenceSamples,
+	}
+}
+
+func (s *silenceEndpointedReader) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	n, err := s.r.Read(p)
+	for i := 0; i < n; i++ {
+		// samples may be split between reads
+		if !s.hasLow {
+			s.low = p[i]
+			s.hasLow = true
+			continue
+		}
+		s.hasLow = false
+		amplitude := int(int16(uint16(s.low) | uint16(p[i])<<8))
+		if amplitude < 0 {
+			amplitude = -amplitude
+		}
+
+		s.frameSum += amplitude
+		s.frameCount++
+		if s.frameCount < s.frameSamples {
+			continue
+		}
+		loud := s.frameSum/s.frameCount > s.threshold
+		s.frameSum, s.frameCount = 0, 0
+
+		if loud {
+			s.heardSpeech = true
+			s.silentSamples = 0
+			continue
+		}
+		if !s.heardSpeech {
+			continue
+		}
+		s.silentSamples += s.frameSamples
+		if s.silentSamples >= s.silenceSamples {
+			// end the audio with the last of the silence
+			s.done = true
+			return i + 1, nil
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,221 @@
+package houndify_test
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Tests that chunks sent through a channel are read in order by ChannelReader
+func TestChannelReader(t *testing.T) {
+	ch := make(chan []byte)
+	go func() {
+		ch <- []byte("first ")
+		ch <- []byte{}
+		ch <- []byte("second ")
+		ch <- []byte("third")
+		close(ch)
+	}()
+
+	data, err := ioutil.ReadAll(ChannelReader(ch))
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), "first second third")
+}
+
+// Tests BytesPerSecond for some common audio formats, and samples that aren't whole bytes
+func TestBytesPerSecond(t *testing.T) {
+	assert.Equal(t, BytesPerSecond(16000, 16, 1), 32000)
+	assert.Equal(t, BytesPerSecond(8000, 16, 1), 16000)
+	assert.Equal(t, BytesPerSecond(44100, 16, 2), 176400)
+	assert.Equal(t, BytesPerSecond(8000, 8, 1), 8000)
+	assert.Equal(t, BytesPerSecond(8000, 4, 1), 4000)
+	assert.Equal(t, BytesPerSecond(8000, 12, 1), 12000)
+}
+
+// Not a real test, this is run as the command by TestNewCommandAudioStream to print
+// fake audio to stdout
+func TestHelperAudioCommand(t *testing.T) {
+	switch os.Getenv("HOUNDIFY_HELPER_AUDIO_COMMAND") {
+	case "1":
+		os.Stdout.Write(bytes.Repeat([]byte("audio"), 400))
+		os.Exit(0)
+	case "forever":
+		// like a recording that is never stopped
+		for {
+			os.Stdout.Write([]byte("audio"))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// Returns a command that prints fake audio until it's killed
+func endlessAudioCommand() *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperAudioCommand")
+	cmd.Env = append(os.Environ(), "HOUNDIFY_HELPER_AUDIO_COMMAND=forever")
+	return cmd
+}
+
+// Tests that NewCommandAudioStream streams a command's stdout, paced to the given rate
+func TestNewCommandAudioStream(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperAudioCommand")
+	cmd.Env = append(os.Environ(), "HOUNDIFY_HELPER_AUDIO_COMMAND=1")
+
+	start := time.Now()
+	stream, err := NewCommandAudioStream(cmd, 8000)
+	assert.NilError(t, err)
+	data, err := ioutil.ReadAll(stream)
+	assert.NilError(t, err)
+
+	// 2000 bytes at 8000 bytes per second take at least a quarter of a second
+	assert.DeepEqual(t, data, bytes.Repeat([]byte("audio"), 400))
+	elapsed := time.Since(start)
+	assert.Assert(t, elapsed >= 200*time.Millisecond, fmt.Sprint(elapsed))
+	assert.Assert(t, cmd.ProcessState != nil && cmd.ProcessState.Success())
+}
+
+// Returns 16-bit PCM audio of a square wave with the given amplitude, or silence if it's 0
+func pcmAudio(samples int, amplitude int16) []byte {
+	audio := make([]byte, 0, samples*2)
+	for i := 0; i < samples; i++ {
+		sample := amplitude
+		if i%2 == 1 {
+			sample = -amplitude
+		}
+		audio = append(audio, byte(sample), byte(uint16(sample)>>8))
+	}
+	return audio
+}
+
+// Return 16-bit PCM audio of a sine wave with the given amplitude and period in samples
+func sineAudio(samples int, amplitude float64, period int) []byte {
+	audio := make([]byte, 0, samples*2)
+	for i := 0; i < samples; i++ {
+		sample := int16(amplitude * math.Sin(2*math.Pi*float64(i)/float64(period)))
+		audio = append(audio, byte(sample), byte(uint16(sample)>>8))
+	}
+	return audio
+}
+
+// Tests that NewSilenceEndpointedReader ends the audio after the speech is followed by
+// enough silence, and not during leading silence or short pauses
+func TestNewSilenceEndpointedReader(t *testing.T) {
+	// at 8000 samples per second
+	var audio []byte
+	audio = append(audio, pcmAudio(4000, 0)...)     // half a second of leading silence
+	audio = append(audio, pcmAudio(4000, 10000)...) // half a second of speech
+	audio = append(audio, pcmAudio(800, 100)...)    // a tenth of a second pause, below the threshold
+	audio = append(audio, pcmAudio(4000, 10000)...) // more speech
+	audio = append(audio, pcmAudio(8000, 0)...)     // a second of silence
+	audio = append(audio, pcmAudio(4000, 10000)...) // speech after the query ended
+
+	// stops after the first 300ms of the trailing silence
+	expected := 2 * (4000 + 4000 + 800 + 4000 + 2400)
+	for _, reader := range []io.Reader{bytes.NewReader(audio), iotest.OneByteReader(bytes.NewReader(audio))} {
+		data, err := ioutil.ReadAll(NewSilenceEndpointedReader(reader, 8000, 500, 300*time.Millisecond))
+		assert.NilError(t, err)
+		assert.Equal(t, len(data), expected)
+		assert.DeepEqual(t, data, audio[:expected])
+	}
+}
+
+// Tests that NewSilenceEndpointedReader ends a sine wave of speech followed by noisy
+// silence with occasional clicks, which aren't mistaken for speech
+func TestNewSilenceEndpointedReaderNoisy(t *testing.T) {
+	// at 8000 samples per second
+	var audio []byte
+	audio = append(audio, sineAudio(4000, 10000, 40)...) // half a second of speech at 200Hz
+	silence := sineAudio(8000, 150, 9)                   // a second of quiet noise
+	for i := 0; i < len(silence); i += 1000 {
+		// a click every 62.5ms
+		silence[i], silence[i+1] = 0xff, 0x7f
+	}
+	audio = append(audio, silence...)
+
+	// stops after the first 300ms of the trailing silence
+	expected := 2 * (4000 + 2400)
+	data, err := ioutil.ReadAll(NewSilenceEndpointedReader(bytes.NewReader(audio), 8000, 500, 300*time.Millisecond))
+	assert.NilError(t, err)
+	assert.Equal(t, len(data), expected)
+}
+
+// Tests that closing a command audio stream while it's being read kills the command
+func TestCommandAudioStreamCloseWhileReading(t *testing.T) {
+	cmd := endlessAudioCommand()
+	stream, err := NewCommandAudioStream(cmd, 8000)
+	assert.NilError(t, err)
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		ioutil.ReadAll(stream)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.NilError(t, stream.(io.Closer).Close())
+
+	select {
+	case <-readDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reading didn't end after the stream was closed")
+	}
+	assert.Assert(t, cmd.ProcessState != nil)
+	assert.NilError(t, stream.(io.Closer).Close())
+}
+
+// Tests that a voice search that ends before the audio does kills and reaps the command
+// recording it
+func TestVoiceSearchClosesCommandAudioStream(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(testServerResponse))
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	cmd := endlessAudioCommand()
+	stream, err := NewCommandAudioStream(cmd, 8000)
+	assert.NilError(t, err)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = stream
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+
+	_, err = houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Assert(t, cmd.ProcessState != nil)
+}
+
+// Tests that a voice search leaves other AudioStreams open, so they can be reused
+func TestVoiceSearchLeavesFileOpen(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		ioutil.ReadAll(req.Body)
+		return NewTestResponse(200, NewTestVoiceResponseBody(testServerResponse))
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	f, err := ioutil.TempFile("", "houndify")
+	assert.NilError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(make([]byte, 64))
+	assert.NilError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NilError(t, err)
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = f
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err = houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NilError(t, err)
+}
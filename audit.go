@@ -0,0 +1,54 @@
+package houndify
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// auditRequest is the canonical form of an outgoing request used for auditing
+type auditRequest struct {
+	Method      string
+	URL         string
+	Headers     http.Header
+	RequestInfo map[string]interface{}
+}
+
+// SerializeRequestForAudit builds the request that would be sent for the TextRequest
+// and returns a canonical JSON serialization of it, including the headers and the
+// RequestInfo, suitable for storing alongside the request signature. The ClientKey is
+// never included.
+//
+// The serialization is deterministic, but note requests are timestamped using the
+// Client's Clock, so the same TextRequest serializes differently at different times.
+func (c Client) SerializeRequestForAudit(textReq TextRequest) ([]byte, error) {
+	req, err := BuildRequest(&textReq, c)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range textReq.headers {
+		req.Header.Set(k, v)
+	}
+
+	audit := auditRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header,
+	}
+
+	// the RequestInfo is either in a header or the body, include it in one place
+	requestInfoJSON := []byte(req.Header.Get("Hound-Request-Info"))
+	if req.Header.Get("Hound-Request-Info-Length") != "" {
+		requestInfoJSON, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read request info")
+		}
+	}
+	if err := json.Unmarshal(requestInfoJSON, &audit.RequestInfo); err != nil {
+		return nil, errors.Wrap(err, "failed to decode request info")
+	}
+	audit.Headers.Del("Hound-Request-Info")
+
+	return json.Marshal(audit)
+}
@@ -0,0 +1,46 @@
+package houndify_test
+
+import (
+	"encoding/json"
+	"gotest.tools/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the audit serialization is stable and doesn't contain the client key
+func TestSerializeRequestForAudit(t *testing.T) {
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.Clock = func() time.Time { return time.Unix(1565000000, 0) }
+
+	first, err := houndifyClient.SerializeRequestForAudit(NewTestTextRequest())
+	assert.NilError(t, err)
+	second, err := houndifyClient.SerializeRequestForAudit(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	assert.Assert(t, !strings.Contains(string(first), houndifyClient.ClientKey))
+	assert.Assert(t, !strings.Contains(string(first), strings.TrimRight(houndifyClient.ClientKey, "=")))
+
+	audit := struct {
+		Method      string
+		URL         string
+		Headers     map[string][]string
+		RequestInfo map[string]interface{}
+	}{}
+	assert.NilError(t, json.Unmarshal(first, &audit))
+	assert.Equal(t, audit.Method, "POST")
+	assert.Equal(t, audit.URL, "http://test.com/v1/text?query=what%20is%20the%20time")
+	assert.Equal(t, audit.Headers["Hound-Request-Authentication"][0], "TestUserID;TestRequestID")
+	assert.Equal(t, audit.RequestInfo["TimeStamp"], float64(1565000000))
+	assert.Equal(t, audit.RequestInfo["ClientID"], houndifyClient.ClientID)
+
+	// the RequestInfo is serialized the same way when sent in the body
+	houndifyClient.RequestInfoInBody = true
+	inBody, err := houndifyClient.SerializeRequestForAudit(NewTestTextRequest())
+	assert.NilError(t, err)
+	inBodyAudit := audit
+	inBodyAudit.RequestInfo = nil
+	assert.NilError(t, json.Unmarshal(inBody, &inBodyAudit))
+	assert.DeepEqual(t, inBodyAudit.RequestInfo, audit.RequestInfo)
+}
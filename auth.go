@@ -16,13 +16,12 @@ type authInfo struct {
 	timeStamp        int64
 }
 
-func generateAuthValues(clientID, clientKey, userID, requestID string) (
+func generateAuthValues(clientID, clientKey, userID, requestID string, now time.Time) (
 	houndClientAuth, houndRequestAuth string, timeStamp int64, returnErr error) {
 
-	timeStamp = time.Now().Unix()
+	timeStamp = now.Unix()
 
-	// base64 decode key
-	decodedClientKey, err := base64.StdEncoding.DecodeString(unescapeBase64Url(clientKey))
+	decodedClientKey, err := decodeClientKey(clientKey)
 	if err != nil {
 		fmt.Println(err)
 		returnErr = errors.New("failed to decode client key")
@@ -39,6 +38,12 @@ func generateAuthValues(clientID, clientKey, userID, requestID string) (
 	return
 }
 
+// decodeClientKey base64 decodes a client key. The padding is stripped as keys are
+// sometimes provided without it.
+func decodeClientKey(clientKey string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(unescapeBase64Url(clientKey), "="))
+}
+
 func unescapeBase64Url(input string) string {
 	return strings.Replace(strings.Replace(input, "-", "+", -1), "_", "/", -1)
 }
@@ -0,0 +1,65 @@
+package houndify_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"strings"
+	"testing"
+)
+
+// Tests that client keys with and without base64 padding sign requests correctly
+func TestClientKeyPadding(t *testing.T) {
+	paddedKey := NewTestHoundifyClient(nil).ClientKey
+	assert.Assert(t, strings.HasSuffix(paddedKey, "="))
+	decodedKey, err := base64.URLEncoding.DecodeString(paddedKey)
+	assert.NilError(t, err)
+
+	for _, key := range []string{paddedKey, strings.TrimRight(paddedKey, "=")} {
+		houndifyClient := NewTestHoundifyClient(nil)
+		houndifyClient.ClientKey = key
+		textReq := NewTestTextRequest()
+		req, err := BuildRequest(&textReq, houndifyClient)
+		assert.NilError(t, err)
+
+		// Hound-Client-Authentication is "ClientID;TimeStamp;Signature"
+		clientAuth := strings.Split(req.Header.Get("Hound-Client-Authentication"), ";")
+		assert.Equal(t, len(clientAuth), 3)
+		mac := hmac.New(sha256.New, decodedKey)
+		mac.Write([]byte(textReq.UserID + ";" + textReq.RequestID + clientAuth[1]))
+		assert.Equal(t, clientAuth[2], base64.URLEncoding.EncodeToString(mac.Sum(nil)))
+	}
+}
+
+// Tests that the hashed UserID is used in both the auth headers and the RequestInfo
+func TestUserIDHasher(t *testing.T) {
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.UserIDHasher = func(userID string) string {
+		sum := sha256.Sum256([]byte(userID))
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256([]byte("TestUserID"))
+	hashedUserID := hex.EncodeToString(sum[:])
+
+	textReq := NewTestTextRequest()
+	req, err := BuildRequest(&textReq, houndifyClient)
+	assert.NilError(t, err)
+
+	assert.Equal(t, req.Header.Get("Hound-Request-Authentication"), hashedUserID+";TestRequestID")
+	reqInfo := make(map[string]interface{})
+	assert.NilError(t, json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo))
+	assert.Equal(t, reqInfo["UserID"], hashedUserID)
+	assert.Assert(t, !strings.Contains(req.Header.Get("Hound-Request-Info"), "TestUserID"))
+
+	// the signature is made with the hashed UserID
+	clientAuth := strings.Split(req.Header.Get("Hound-Client-Authentication"), ";")
+	decodedKey, err := base64.URLEncoding.DecodeString(houndifyClient.ClientKey)
+	assert.NilError(t, err)
+	mac := hmac.New(sha256.New, decodedKey)
+	mac.Write([]byte(hashedUserID + ";TestRequestID" + clientAuth[1]))
+	assert.Equal(t, clientAuth[2], base64.URLEncoding.EncodeToString(mac.Sum(nil)))
+}
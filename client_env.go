@@ -0,0 +1,33 @@
+package houndify
+
+import (
+	"github.com/pkg/errors"
+	"os"
+)
+
+// The environment variables ClientFromEnv reads the client credentials from
+const (
+	EnvClientID  = "HOUNDIFY_CLIENT_ID"
+	EnvClientKey = "HOUNDIFY_CLIENT_KEY"
+)
+
+// ClientFromEnv returns a Client with the ClientID and ClientKey read from the
+// HOUNDIFY_CLIENT_ID and HOUNDIFY_CLIENT_KEY environment variables. An error is returned
+// if either is missing, or the ClientKey isn't a valid key.
+func ClientFromEnv() (*Client, error) {
+	clientID := os.Getenv(EnvClientID)
+	if clientID == "" {
+		return nil, errors.Errorf("the client ID must be set in the %s environment variable", EnvClientID)
+	}
+	clientKey := os.Getenv(EnvClientKey)
+	if clientKey == "" {
+		return nil, errors.Errorf("the client key must be set in the %s environment variable", EnvClientKey)
+	}
+	if _, err := decodeClientKey(clientKey); err != nil {
+		return nil, errors.Wrapf(err, "the client key in the %s environment variable is invalid", EnvClientKey)
+	}
+	return &Client{
+		ClientID:  clientID,
+		ClientKey: clientKey,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package houndify_test
+
+import (
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"os"
+	"testing"
+)
+
+// Sets an environment variable for the rest of the test, restoring it afterwards
+func setenvForTest(t *testing.T, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	assert.NilError(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+// Tests that ClientFromEnv reads and validates the client credentials
+func TestClientFromEnv(t *testing.T) {
+	testClient := NewTestHoundifyClient(nil)
+	defer setenvForTest(t, EnvClientID, testClient.ClientID)()
+	defer setenvForTest(t, EnvClientKey, testClient.ClientKey)()
+
+	client, err := ClientFromEnv()
+	assert.NilError(t, err)
+	assert.Equal(t, client.ClientID, testClient.ClientID)
+	assert.Equal(t, client.ClientKey, testClient.ClientKey)
+
+	os.Setenv(EnvClientKey, "not a valid key!")
+	_, err = ClientFromEnv()
+	assert.ErrorContains(t, err, "is invalid")
+
+	os.Unsetenv(EnvClientKey)
+	_, err = ClientFromEnv()
+	assert.ErrorContains(t, err, EnvClientKey)
+
+	os.Unsetenv(EnvClientID)
+	_, err = ClientFromEnv()
+	assert.ErrorContains(t, err, EnvClientID)
+}
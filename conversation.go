@@ -0,0 +1,108 @@
+package houndify
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// A Conversation wraps a Client and keeps track of the turns in a conversation with a
+// single user. The conversation state is managed automatically, so each query is
+// interpreted in the context of the previous ones.
+//
+// A Conversation is not safe for concurrent use.
+type Conversation struct {
+	// The UserID sent with every request in the conversation
+	UserID string
+
+	client  *Client
+	state   interface{}
+	history []ConversationTurn
+}
+
+// A ConversationTurn is a single query and response exchange in a Conversation.
+type ConversationTurn struct {
+	// The text query sent to the server
+	Query string
+	// The final server response JSON
+	ServerResponse string
+	// The human readable response parsed out of the ServerResponse
+	WrittenResponse string
+	// The conversation state after this turn
+	ConversationState interface{}
+}
+
+// NewConversation creates a Conversation for the given user that sends its requests
+// using the client. Conversation state is enabled on the client.
+func NewConversation(client *Client, userID string) *Conversation {
+	client.EnableConversationState()
+	return &Conversation{
+		UserID: userID,
+		client: client,
+	}
+}
+
+// Ask sends the text query to the server using the conversation's current state,
+// records the exchange in the conversation's history and returns it.
+//
+// If the request fails, the turn isn't recorded and the conversation state is left
+// unchanged.
+func (conv *Conversation) Ask(ctx context.Context, query string) (ConversationTurn, error) {
+	req := TextRequest{
+		Query:             query,
+		UserID:            conv.UserID,
+		RequestID:         newRequestID(),
+		RequestInfoFields: make(map[string]interface{}),
+	}
+	if ctx != nil {
+		req.WithContext(ctx)
+	}
+
+	// The client's state may have been changed by other conversations, so always send
+	// this conversation's state
+	conv.client.SetConversationState(conv.state)
+	serverResponse, err := conv.client.TextSearch(req)
+	if err != nil {
+		return ConversationTurn{}, err
+	}
+	result, err := parseResult(serverResponse, 0)
+	if err != nil {
+		return ConversationTurn{}, err
+	}
+
+	conv.state = conv.client.GetConversationState()
+	turn := ConversationTurn{
+		Query:             query,
+		ServerResponse:    serverResponse,
+		WrittenResponse:   result.WrittenResponseLong,
+		ConversationState: conv.state,
+	}
+	conv.history = append(conv.history, turn)
+	return turn, nil
+}
+
+// History returns the turns of the conversation so far, oldest first.
+func (conv *Conversation) History() []ConversationTurn {
+	history := make([]ConversationTurn, len(conv.history))
+	copy(history, conv.history)
+	return history
+}
+
+// ConversationState returns the current state of the conversation.
+func (conv *Conversation) ConversationState() interface{} {
+	return conv.state
+}
+
+// Reset forgets the conversation's history and state.
+func (conv *Conversation) Reset() {
+	conv.state = nil
+	conv.history = nil
+}
+
+// newRequestID creates a pseudo unique/random request ID, so each request to the
+// Hound server is signed differently.
+func newRequestID() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return fmt.Sprintf("%X", b)
+}
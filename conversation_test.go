@@ -0,0 +1,77 @@
+package houndify_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"testing"
+)
+
+// Tests a multi turn Conversation, ensure the following:
+// - Each turn is recorded in the history
+// - The state returned by one turn is sent with the next
+func TestConversationAsk(t *testing.T) {
+	turn := 0
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		reqInfo := make(map[string]interface{})
+		err := json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo)
+		assert.NilError(t, err)
+
+		if turn == 0 {
+			assert.Equal(t, reqInfo["ConversationState"], nil)
+		} else {
+			state := reqInfo["ConversationState"].(map[string]interface{})
+			assert.Equal(t, state["Turn"], float64(turn))
+		}
+		turn++
+
+		return NewTestResponse(200, fmt.Sprintf(`{
+			"Status": "OK",
+			"NumToReturn": 1,
+			"AllResults": [{
+				"WrittenResponseLong": "Response %d",
+				"ConversationState": {"Turn": %d}
+			}]
+		}`, turn, turn))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	conv := NewConversation(&houndifyClient, "TestUserID")
+
+	first, err := conv.Ask(context.Background(), "what is two plus six")
+	assert.NilError(t, err)
+	assert.Equal(t, first.WrittenResponse, "Response 1")
+
+	second, err := conv.Ask(context.Background(), "minus four")
+	assert.NilError(t, err)
+	assert.Equal(t, second.WrittenResponse, "Response 2")
+
+	history := conv.History()
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[0].Query, "what is two plus six")
+	assert.Equal(t, history[1].Query, "minus four")
+	state := conv.ConversationState().(map[string]interface{})
+	assert.Equal(t, state["Turn"], float64(2))
+}
+
+// Tests that a turn whose result has no written response is recorded without one
+func TestConversationAskWithoutWrittenResponse(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, `{
+			"Status": "OK",
+			"NumToReturn": 1,
+			"AllResults": [{"SpokenResponse": "It is noon"}]
+		}`)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	conv := NewConversation(&houndifyClient, "TestUserID")
+
+	turn, err := conv.Ask(context.Background(), "what time is it")
+	assert.NilError(t, err)
+	assert.Equal(t, turn.WrittenResponse, "")
+	assert.Equal(t, len(conv.History()), 1)
+}
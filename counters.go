@@ -0,0 +1,46 @@
+package houndify
+
+import (
+	"net/http"
+)
+
+// ClientCounters holds the running totals of a Client's requests.
+type ClientCounters struct {
+	// Number of requests sent to the server
+	Requests int64
+	// Number of requests that failed before a response was received
+	FailedRequests int64
+	// Number of error responses (HTTP status 400 or above) received
+	ErrorResponses int64
+}
+
+// Counters returns the Client's request totals since it was created or the counters
+// were last reset.
+func (c *Client) Counters() ClientCounters {
+	shared := c.getShared()
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return shared.counters
+}
+
+// ResetCounters sets all of the Client's request totals back to zero, e.g. at the
+// start of a new reporting interval.
+func (c *Client) ResetCounters() {
+	shared := c.getShared()
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	shared.counters = ClientCounters{}
+}
+
+// countRequest updates the counters with the outcome of sending a request
+func (c *Client) countRequest(resp *http.Response, err error) {
+	shared := c.getShared()
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	shared.counters.Requests++
+	if err != nil {
+		shared.counters.FailedRequests++
+	} else if resp.StatusCode >= 400 {
+		shared.counters.ErrorResponses++
+	}
+}
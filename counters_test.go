@@ -0,0 +1,26 @@
+package houndify_test
+
+import (
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"testing"
+)
+
+// Tests that requests are counted, and ResetCounters zeroes the counts
+func TestResetCounters(t *testing.T) {
+	statusCode := 200
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(statusCode, testServerResponse)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.TextSearch(NewTestTextRequest())
+	statusCode = 500
+	houndifyClient.TextSearch(NewTestTextRequest())
+
+	assert.Equal(t, houndifyClient.Counters(), ClientCounters{Requests: 2, ErrorResponses: 1})
+
+	houndifyClient.ResetCounters()
+	assert.Equal(t, houndifyClient.Counters(), ClientCounters{})
+}
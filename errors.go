@@ -0,0 +1,84 @@
+package houndify
+
+import (
+	"errors"
+	"net"
+	"net/url"
+)
+
+// ErrInvalidUTF8 is returned by TextSearch when Client.ValidateQueryUTF8 is set and the
+// query isn't valid UTF-8.
+var ErrInvalidUTF8 = errors.New("query is not valid UTF-8")
+
+// ErrResponseTooLarge is returned when a server response is larger than the Client's
+// MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("server response is too large")
+
+// ErrStopped is returned by VoiceSearch when the request is aborted by closing its
+// StopCh.
+var ErrStopped = errors.New("voice request stopped")
+
+// ErrClockSkew is the cause of the error returned when a request is rejected as
+// unauthorized and the local clock is too far from the server's, which makes request
+// timestamps invalid. See Client.DetectClockSkew.
+var ErrClockSkew = errors.New("clock skew")
+
+// ErrUnsupportedFormatVersion is the cause of the error returned when a response's
+// FormatVersion is outside the Client's expected range and StrictFormatVersion is set.
+var ErrUnsupportedFormatVersion = errors.New("unsupported response format version")
+
+// ErrRequestInfoTooLarge is the cause of the error returned when the RequestInfo is
+// larger than the Client's MaxRequestInfoHeaderBytes and can't be sent in the body.
+var ErrRequestInfoTooLarge = errors.New("request info is too large for a header")
+
+// ErrTooManyVoiceRequests is returned by VoiceSearch when the Client already has
+// MaxConcurrentVoice voice requests in flight and RejectConcurrentVoice is set.
+var ErrTooManyVoiceRequests = errors.New("too many concurrent voice requests")
+
+// ErrOffline is returned, wrapping the underlying network error, when a request fails
+// because the server can't be reached, e.g. there is no connectivity. Check for it with
+// errors.Cause from github.com/pkg/errors, or with errors.Is on Go 1.13 and later.
+var ErrOffline = errors.New("houndify server is unreachable")
+
+// offlineError wraps a network error that means the server couldn't be reached
+type offlineError struct {
+	err error
+}
+
+func (e *offlineError) Error() string {
+	return ErrOffline.Error() + ": " + e.err.Error()
+}
+
+// Is makes errors.Is(err, ErrOffline) true on Go 1.13 and later
+func (e *offlineError) Is(target error) bool {
+	return target == ErrOffline
+}
+
+// Unwrap returns the underlying network error
+func (e *offlineError) Unwrap() error {
+	return e.err
+}
+
+// Cause makes errors.Cause(err) == ErrOffline for github.com/pkg/errors users
+func (e *offlineError) Cause() error {
+	return ErrOffline
+}
+
+// offlineErr returns an ErrOffline error wrapping err if err, as returned by an
+// http.Client, means the server couldn't be reached. Otherwise it returns nil.
+func offlineErr(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	switch netErr := err.(type) {
+	case *net.OpError:
+		// failing to connect, including failing to resolve the host
+		if netErr.Op != "dial" {
+			return nil
+		}
+	case *net.DNSError:
+	default:
+		return nil
+	}
+	return &offlineError{err: err}
+}
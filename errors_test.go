@@ -0,0 +1,33 @@
+package houndify_test
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that failing to connect to the server returns ErrOffline for both searches
+func TestOfflineError(t *testing.T) {
+	// a closed server's address refuses connections
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+
+	textReq := NewTestTextRequest()
+	textReq.URL = server.URL + "/v1/text"
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.Equal(t, errors.Cause(err), ErrOffline, "got %v", err)
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.URL = server.URL + "/v1/voice"
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err = houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.Equal(t, errors.Cause(err), ErrOffline, "got %v", err)
+}
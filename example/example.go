@@ -8,16 +8,13 @@ import (
 	"crypto/tls"
 	"flag"
 	"fmt"
-	"github.com/go-audio/wav"
 	houndify "github.com/soundhound/houndify-sdk-go"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http/httptrace"
 	"net/textproto"
 	"os"
 	"strings"
-	"time"
 )
 
 const (
@@ -26,8 +23,8 @@ const (
 	// See https://www.houndify.com/docs/ for more details.
 	userID = "exampleUser"
 
-	envClientIDKey  = "HOUNDIFY_CLIENT_ID"
-	envClientKeyKey = "HOUNDIFY_CLIENT_KEY"
+	envClientIDKey  = houndify.EnvClientID
+	envClientKeyKey = houndify.EnvClientKey
 
 	cliClientIDKey  = "id"
 	cliClientKeyKey = "key"
@@ -164,102 +161,31 @@ func main() {
 		}
 
 	case *voiceFlag != "" && *streamFlag:
-		StreamAudio(client, *voiceFlag, userID)
-	}
-}
-
-// Stream an audio file to the server. This example demonstrates streaming a wav file,
-// however this could easily be changed to stream audio from a microphone or something.
-// Basically it just writes data from a buffer to the Request body every 1 second. The
-// advantage of how golang has the http.Request's Body field is it's a Reader, so using
-// io.Pipe() you can actually write any data into it. That means any stream of WAV data
-// can just be piped in, and the requests will be made.
-//
-// This function also demonstrates how you can use the SafeToStopAudio flag to know when
-// the server has all the data it needs.
-func StreamAudio(client houndify.Client, fname, uid string) {
-	f, err := os.Open(fname)
-	defer f.Close()
-	if err != nil {
-		log.Fatalf("failed to read contents of file %q, err: %v\n", fname, err)
-	}
-
-	// Read WAV file data, determine bytes per second
-	d := wav.NewDecoder(f)
-	d.ReadInfo()
-
-	// Use 1 second chunks
-	bps := int(d.AvgBytesPerSec) * 1
-
-	// Build pipe that lets us write into the io.Reader that is in the request
-	rp, wp := io.Pipe()
-
-	req := houndify.VoiceRequest{
-		AudioStream: rp,
-		UserID:      uid,
-		RequestID:   createRequestID(),
-	}
-
-	// Start the function to write 1 second of data per 1 real second, by using a buffer
-	// that is the size of 1 second of data. Note that using the .Read() function results
-	// in the header portion of the file not being read. We have to use the ReadAt()
-	// function to specify starting at the very first position of the actual file, or the
-	// header isn't read.
-	var loc int64 = 0
-	buf := make([]byte, bps)
-	done := make(chan bool)
-	go func(wp *io.PipeWriter) {
-		defer wp.Close()
-
-		for {
-			select {
-			case <-done:
-				//fmt.Println("Exiting write loop")
-				return
-			default:
-				n, err := f.ReadAt(buf, loc)
-				loc += int64(n)
-
-				// At the EOF, the buffer will still have bytes read into it, have to write
-				// those out before breaking the loop
-				if err == io.EOF {
-					wp.Write(buf[:n])
-					return
-				}
-
-				// Write the amount of bytes that were read in
-				wp.Write(buf[:n])
-				time.Sleep(time.Duration(1) * time.Second)
-			}
+		// stream the file in real time, as if it was being recorded
+		// the helper stops sending audio once the server says it's safe to stop
+		req := houndify.VoiceRequest{
+			UserID:            userID,
+			RequestID:         createRequestID(),
+			RequestInfoFields: make(map[string]interface{}),
 		}
-	}(wp)
-
-	// listen for partial transcript responses
-	partialTranscripts := make(chan houndify.PartialTranscript)
-	go func() {
-		for partial := range partialTranscripts {
-			if partial.SafeToStopAudio != nil && *partial.SafeToStopAudio == true {
-				fmt.Println("Safe to stop audio recieved")
-				if done != nil {
-					done <- true
+		serverResponse, err := houndify.StreamFileRequestWithProgress(&client, req, *voiceFlag,
+			func(partial houndify.PartialTranscript) {
+				if partial.SafeToStopAudio != nil && *partial.SafeToStopAudio {
+					fmt.Println("Safe to stop audio recieved")
 				}
-				return
-			}
-			if partial.Message != "" { // ignore the "" partial transcripts, not really useful
-				fmt.Println(partial.Message)
-			}
+				if partial.Message != "" { // ignore the "" partial transcripts, not really useful
+					fmt.Println(partial.Message)
+				}
+			}, nil)
+		if err != nil {
+			log.Fatalf("failed to make voice request: %v\n%s\n", err, serverResponse)
 		}
-	}()
-
-	serverResponse, err := client.VoiceSearch(req, partialTranscripts)
-	if err != nil {
-		log.Fatalf("failed to make voice request: %v\n%s\n", err, serverResponse)
-	}
-	writtenResponse, err := houndify.ParseWrittenResponse(serverResponse)
-	if err != nil {
-		log.Fatalf("failed to decode hound response\n%s\n", serverResponse)
+		writtenResponse, err := houndify.ParseWrittenResponse(serverResponse)
+		if err != nil {
+			log.Fatalf("failed to decode hound response\n%s\n", serverResponse)
+		}
+		fmt.Println(writtenResponse)
 	}
-	fmt.Println(writtenResponse)
 }
 
 // Creates a pseudo unique/random request ID.
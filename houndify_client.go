@@ -2,21 +2,46 @@ package houndify
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 const houndifyVoiceURL = "https://api.houndify.com:443/v1/audio"
 const houndifyTextURL = "https://api.houndify.com:443/v1/text"
 
+// How far the local clock can be from the server's before unauthorized responses are
+// attributed to clock skew
+const clockSkewTolerance = time.Minute
+
+// The Formats of the messages recognized as partial transcripts, if the Client doesn't set
+// its own PartialTranscriptFormats. "SoundHoundVoiceSearchParialTranscript" is how the
+// server spells it, the corrected spelling is accepted in case that changes.
+var DefaultPartialTranscriptFormats = []string{
+	"HoundVoiceQueryPartialTranscript",
+	"SoundHoundVoiceSearchParialTranscript",
+	"SoundHoundVoiceSearchPartialTranscript",
+}
+
+// The Formats of the messages recognized as the final result of a voice search, if the
+// Client doesn't set its own ResultFormats
+var DefaultResultFormats = []string{
+	"SoundHoundVoiceSearchResult",
+}
+
 // Default user agent set by the SDK
 const SDKUserAgent = "Go Houndify SDK"
 
@@ -33,11 +58,85 @@ type (
 		conversationState       interface{}
 		// If Verbose is true, all data sent from the server is printed to stdout, unformatted and unparsed.
 		// This includes partial transcripts, errors, HTTP headers details (status code, headers, etc.), and final response JSON.
-		Verbose           bool
-		HttpClient        *http.Client
+		Verbose    bool
+		HttpClient *http.Client
+		// If RequestInfoInBody is true, the RequestInfo is sent in the request body instead
+		// of the Hound-Request-Info header, which avoids header size limits for a large
+		// RequestInfo. For voice requests it is sent ahead of the audio.
 		RequestInfoInBody bool
+		// MaxRequestInfoHeaderBytes, if non-zero, is the largest RequestInfo that is sent
+		// in the Hound-Request-Info header. A larger RequestInfo fails the request with an
+		// error caused by ErrRequestInfoTooLarge, or if RequestInfoOverflowToBody is true,
+		// is sent in the body as if RequestInfoInBody was set.
+		MaxRequestInfoHeaderBytes int
+		RequestInfoOverflowToBody bool
+		// ConversationStateTransform, if set, is applied to the conversation state
+		// returned by the server before it is stored on the Client. It can be used to
+		// augment the state with app specific fields (e.g. a turn counter).
+		ConversationStateTransform func(state interface{}) interface{}
+		// If ValidateQueryUTF8 is true, text queries that aren't valid UTF-8 are rejected
+		// with ErrInvalidUTF8 before being sent.
+		ValidateQueryUTF8 bool
+		// Logger receives warnings noticed while handling responses, such as a mismatch
+		// between the expected and received audio length. If nil, warnings are printed
+		// to stdout.
+		Logger *log.Logger
+		// MaxResponseBytes limits the size of a server response. If a response is any
+		// larger, reading it is aborted and ErrResponseTooLarge is returned. Zero means
+		// there is no limit.
+		MaxResponseBytes int64
+		// If CookieJar is set, it is used to store cookies set by the server and send them
+		// with later requests, e.g. for load balancers using sticky sessions.
+		CookieJar http.CookieJar
+		// ResponseHeaderTimeout, if non-zero, limits how long to wait for the server to
+		// start responding after the request is sent, independent of how long the whole
+		// response takes to stream. If the HttpClient has its own *http.Transport, a copy
+		// of it with the timeout is used. Any other RoundTripper is used as is, so set the
+		// timeout on it instead.
+		ResponseHeaderTimeout time.Duration
+		// Clock returns the current time, which is used to timestamp and sign requests.
+		// If nil, time.Now is used.
+		Clock func() time.Time
+		// ResponseMiddleware, if set, transforms the final response body after it is read
+		// and before it is parsed or returned, e.g. to redact or enrich it. Returning an
+		// error fails the request.
+		ResponseMiddleware func(body string) (string, error)
+		// UserIDHasher, if set, is applied to each request's UserID before it's used, so
+		// raw user identifiers are never sent. The hashed value is used both to sign the
+		// request and in the RequestInfo, so the hasher must be deterministic.
+		UserIDHasher func(userID string) string
+		// If DetectClockSkew is true, when a request is rejected as unauthorized the
+		// server's Date header is compared against the local clock, and an error with
+		// ErrClockSkew as its cause is returned if they are too far apart.
+		DetectClockSkew bool
+		// MinFormatVersion and MaxFormatVersion, if set, are the range of FormatVersions
+		// (e.g. "1.0") of final server responses the app expects. A response outside the
+		// range logs a warning, or if StrictFormatVersion is true, fails the request with
+		// an error caused by ErrUnsupportedFormatVersion.
+		MinFormatVersion    string
+		MaxFormatVersion    string
+		StrictFormatVersion bool
+		// PartialTranscriptFormats are the Formats of the voice response messages that are
+		// partial transcripts. If empty, DefaultPartialTranscriptFormats is used.
+		PartialTranscriptFormats []string
+		// ResultFormats are the Formats of the voice response messages that are the final
+		// result. If empty, DefaultResultFormats is used.
+		ResultFormats []string
+		// MaxConcurrentVoice, if non-zero, limits how many voice requests the Client, and
+		// its copies, stream at once. Requests beyond the limit wait for one to finish, or
+		// if RejectConcurrentVoice is true, fail immediately with ErrTooManyVoiceRequests.
+		// The limit is fixed by the first limited voice request, changing it afterwards has
+		// no effect. Concurrent requests on the same Client require conversation state
+		// to be disabled, as each request updates it.
+		MaxConcurrentVoice    int
+		RejectConcurrentVoice bool
+
+		shared *clientShared
 	}
 
+	// A VoiceCompletion describes how a voice search's response stream ended
+	VoiceCompletion int
+
 	// all of the Hound server JSON messages have these basic fields
 	houndServerMessage struct {
 		Format  string `json:"Format"`
@@ -45,19 +144,128 @@ type (
 	}
 	houndServerPartialTranscript struct {
 		houndServerMessage
-		PartialTranscript string `json:"PartialTranscript"`
-		DurationMS        int64  `json:"DurationMS"`
-		Done              bool   `json:"Done"`
-		SafeToStopAudio   *bool  `json:"SafeToStopAudio"`
+		PartialTranscript *string `json:"PartialTranscript"`
+		DurationMS        int64   `json:"DurationMS"`
+		Done              bool    `json:"Done"`
+		SafeToStopAudio   *bool   `json:"SafeToStopAudio"`
+		Language          string  `json:"Language"`
 	}
 )
 
+const (
+	// The request failed before the response stream ended
+	VoiceIncomplete VoiceCompletion = iota
+	// The response stream ended normally with the final result
+	VoiceFinalResult
+	// The response stream ended without a final result, so it was likely cut short
+	VoiceEndedWithoutResult
+)
+
+func (v VoiceCompletion) String() string {
+	switch v {
+	case VoiceFinalResult:
+		return "final result"
+	case VoiceEndedWithoutResult:
+		return "ended without result"
+	default:
+		return "incomplete"
+	}
+}
+
+// clientShared holds the parts of a Client that are shared between copies of it and
+// must be safe for concurrent use
+type clientShared struct {
+	mu       sync.Mutex
+	counters ClientCounters
+	// the transport used when the Client has a ResponseHeaderTimeout, and the
+	// HttpClient's transport it was copied from, if any
+	transport     *http.Transport
+	transportBase *http.Transport
+	// the semaphore used when the Client has a MaxConcurrentVoice
+	voiceSlots chan struct{}
+	// closed by CancelAll to cancel all of the Client's requests
+	canceled   chan struct{}
+	cancelOnce sync.Once
+}
+
+// guards the lazy creation of each Client's clientShared
+var clientSharedInit sync.Mutex
+
+// getShared returns the Client's shared state, creating it if needed. It must be called
+// before the Client is copied, so copies share the same state.
+func (c *Client) getShared() *clientShared {
+	clientSharedInit.Lock()
+	defer clientSharedInit.Unlock()
+	if c.shared == nil {
+		c.shared = &clientShared{canceled: make(chan struct{})}
+	}
+	return c.shared
+}
+
+// CancelAll cancels all of the Client's in-flight requests, which return an error with
+// context.Canceled as its cause. It is meant for shutting down: the Client, and any
+// copies of it, must not be reused afterwards as their new requests are canceled too.
+func (c *Client) CancelAll() {
+	shared := c.getShared()
+	shared.cancelOnce.Do(func() {
+		close(shared.canceled)
+	})
+}
+
+// canceledErr returns an error if CancelAll has been called on the Client
+func (c *Client) canceledErr() error {
+	select {
+	case <-c.getShared().canceled:
+		return errors.Wrap(context.Canceled, "client requests were canceled")
+	default:
+		return nil
+	}
+}
+
+// cancelOn returns a copy of req whose context is also canceled when done is closed. The
+// returned cancel func must be called to release its resources.
+func cancelOn(req *http.Request, done <-chan struct{}) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return req.WithContext(ctx), cancel
+}
+
+// String describes the Client with its credentials masked, so formatting a Client (e.g.
+// with %+v) for logging never leaks the ClientKey.
+func (c Client) String() string {
+	return fmt.Sprintf("houndify.Client{ClientID: %q, ClientKey: %q}", maskCredential(c.ClientID, 4), maskCredential(c.ClientKey, 0))
+}
+
+// GoString masks the Client's credentials like String, for the %#v verb
+func (c Client) GoString() string {
+	return c.String()
+}
+
+// maskCredential replaces all but the first visible characters of a credential
+func maskCredential(credential string, visible int) string {
+	if credential == "" {
+		return ""
+	}
+	if len(credential) <= visible {
+		visible = 0
+	}
+	return credential[:visible] + "****"
+}
+
 // EnableConversationState enables conversation state for future queries
 func (c *Client) EnableConversationState() {
 	c.enableConversationState = true
 }
 
-// DisableConversationState disables conversation state for future queries
+// DisableConversationState disables conversation state for future queries. Stateless
+// requests skip all conversation state handling, which saves work for high volume
+// services that have no use for it.
 func (c *Client) DisableConversationState() {
 	c.enableConversationState = false
 }
@@ -73,11 +281,43 @@ func (c *Client) GetConversationState() interface{} {
 	return c.conversationState
 }
 
+// ConversationStateMap returns the current conversation state as a map, useful for
+// inspecting it. The bool is false if there is no state or it isn't a JSON object.
+func (c *Client) ConversationStateMap() (map[string]interface{}, bool) {
+	state, ok := c.conversationState.(map[string]interface{})
+	return state, ok
+}
+
 // SetConversationState sets the conversation state, useful for resuming from a saved point
 func (c *Client) SetConversationState(newState interface{}) {
 	c.conversationState = newState
 }
 
+// Warmup makes a cheap HEAD request to the Hound server, so the connection (including
+// the TLS handshake) is already established when the first query is sent and is reused
+// by it. Any response means the connection was made, so only failing to connect is an
+// error.
+func (c *Client) Warmup(ctx context.Context) error {
+	req, err := http.NewRequest("HEAD", houndifyTextURL, nil)
+	if err != nil {
+		return errors.New("failed to build http request: " + err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", SDKUserAgent)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		if offlineErr := offlineErr(err); offlineErr != nil {
+			return offlineErr
+		}
+		return errors.New("failed to warm up the connection: " + err.Error())
+	}
+	// the body must be read to the end for the connection to be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
 // TextSearch sends a text request and returns the body of the Hound server response.
 //
 // An error is returned if there is a failure to create the request, failure to
@@ -85,7 +325,16 @@ func (c *Client) SetConversationState(newState interface{}) {
 // state (if applicable).
 func (c *Client) TextSearch(textReq TextRequest) (string, error) {
 
+	if c.ValidateQueryUTF8 && !utf8.ValidString(textReq.Query) {
+		return "", ErrInvalidUTF8
+	}
+
+	// create the shared state before the Client is copied when building the request
+	c.getShared()
 	req, err := BuildRequest(&textReq, *c)
+	if err != nil {
+		return "", err
+	}
 
 	// Add the TexRequest's context to the http request
 	if textReq.ctx != nil {
@@ -97,48 +346,73 @@ func (c *Client) TextSearch(textReq TextRequest) (string, error) {
 		req.Header.Set(k, v)
 	}
 
-	if err != nil {
-		return "", err
-	}
+	// abort the request when all of the Client's requests are canceled
+	req, cancel := cancelOn(req, c.getShared().canceled)
+	defer cancel()
 
-	if c.HttpClient == nil {
-		c.HttpClient = &http.Client{}
-	}
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.httpClient().Do(req)
+	c.countRequest(resp, err)
 	if err != nil {
+		if canceledErr := c.canceledErr(); canceledErr != nil {
+			return "", canceledErr
+		}
+		if offlineErr := offlineErr(err); offlineErr != nil {
+			return "", offlineErr
+		}
 		return "", errors.New("failed to successfully run request: " + err.Error())
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(c.limitResponse(resp.Body))
+	if err == ErrResponseTooLarge {
+		return "", err
+	}
 	if err != nil {
+		if canceledErr := c.canceledErr(); canceledErr != nil {
+			return "", canceledErr
+		}
 		return "", errors.New("failed to read body: " + err.Error())
 	}
-	defer resp.Body.Close()
 
 	bodyStr := string(body)
 
-	if c.Verbose {
+	if c.isVerbose(textReq.Verbose) {
 		fmt.Println(resp.Proto, resp.StatusCode)
 		fmt.Println("Headers: ", resp.Header)
 		fmt.Println(bodyStr)
 	}
 
+	bodyStr, err = c.applyResponseMiddleware(bodyStr)
+	if err != nil {
+		return "", err
+	}
+
 	//don't try to parse out conversation state from a bad response
 	if resp.StatusCode >= 400 {
+		if err := c.checkClockSkew(resp); err != nil {
+			return bodyStr, err
+		}
 		return bodyStr, errors.New("error response")
 	}
+	if err := c.checkFormatVersion(bodyStr); err != nil {
+		return bodyStr, err
+	}
 	// update with new conversation state
-	if c.enableConversationState {
-		newConvState, err := parseConversationState(bodyStr)
-		if err != nil {
-			return bodyStr, errors.Wrap(err, "unable to parse new conversation state from response")
-		}
-		c.conversationState = newConvState
+	if err := c.updateConversationState(bodyStr); err != nil {
+		return bodyStr, err
 	}
 
 	return bodyStr, nil
 }
 
+// TextSearchParsed is like TextSearch, but returns the response both as the raw body
+// and parsed into a HoundifyResponse, so it only has to be decoded once.
+func (c *Client) TextSearchParsed(textReq TextRequest) (*SearchResponse, error) {
+	bodyStr, err := c.TextSearch(textReq)
+	return newSearchResponse(bodyStr, err)
+}
+
 // VoiceSearch sends an audio request and returns the body of the Hound server response.
 //
 // The partialTranscriptChan parameter allows the caller to receive for PartialTranscripts
@@ -150,22 +424,73 @@ func (c *Client) TextSearch(textReq TextRequest) (string, error) {
 // connect, failure to parse the response, or failure to update the conversation
 // state (if applicable).
 func (c *Client) VoiceSearch(voiceReq VoiceRequest, partialTranscriptChan chan PartialTranscript) (string, error) {
+	bodyStr, _, err := c.voiceSearch(voiceReq, partialTranscriptChan)
+	return bodyStr, err
+}
+
+// VoiceSearchParsed is like VoiceSearch, but returns the response both as the raw body
+// and parsed into a HoundifyResponse, so it only has to be decoded once.
+func (c *Client) VoiceSearchParsed(voiceReq VoiceRequest, partialTranscriptChan chan PartialTranscript) (*SearchResponse, error) {
+	bodyStr, err := c.VoiceSearch(voiceReq, partialTranscriptChan)
+	return newSearchResponse(bodyStr, err)
+}
+
+// VoiceSearchWithCompletion is like VoiceSearch, but also returns how the response
+// stream ended, so a stream that was cut short without a final result can be told
+// apart from one that finished normally.
+func (c *Client) VoiceSearchWithCompletion(voiceReq VoiceRequest, partialTranscriptChan chan PartialTranscript) (string, VoiceCompletion, error) {
+	return c.voiceSearch(voiceReq, partialTranscriptChan)
+}
+
+func (c *Client) voiceSearch(voiceReq VoiceRequest, partialTranscriptChan chan PartialTranscript) (string, VoiceCompletion, error) {
 
 	//so the partial transcript channel doesn't get closed before all transcripts are sent
 	partialChanWait := sync.WaitGroup{}
 
+	// the last partial transcript sent, which the final one is made from if the server
+	// didn't send one that is Done. It's only sent if the search succeeds, as after an
+	// error the transcript may be incomplete.
+	var lastPartial PartialTranscript
+	succeeded := false
+
 	defer func() {
+		sendFinal := succeeded && !lastPartial.Done
+		finalPartial := lastPartial
+		finalPartial.Done = true
+		finalPartial.SafeToStopAudio = nil
+		finalPartial.SafeToStopOffset = 0
 		go func() {
 			//don't close the open partial transcript channel
 			partialChanWait.Wait()
+			// the last value on the channel of a successful search is always Done
+			if sendFinal {
+				partialTranscriptChan <- finalPartial
+			}
 			close(partialTranscriptChan)
 		}()
 	}()
 
-	// Ensure that RequestInfoInBody isn't set for VoiceRequests because the Audio stream
-	// has to go into the body
-	c.RequestInfoInBody = false
+	// the search may end before all of the audio is read, e.g. on a final result, so
+	// stop the command recording the audio of a NewCommandAudioStream
+	if cmdStream, ok := voiceReq.AudioStream.(*commandAudioStream); ok {
+		defer cmdStream.Close()
+	}
+
+	// create the shared state before the Client is copied when building the request
+	c.getShared()
+
+	// wait for a free slot if the number of concurrent voice requests is limited. This is
+	// done before the request is built, so it isn't signed with a stale timestamp.
+	release, err := c.acquireVoiceSlot(&voiceReq)
+	if err != nil {
+		return "", VoiceIncomplete, err
+	}
+	defer release()
+
 	req, err := BuildRequest(&voiceReq, *c)
+	if err != nil {
+		return "", VoiceIncomplete, err
+	}
 	if voiceReq.ctx != nil {
 		req = req.WithContext(voiceReq.ctx)
 	}
@@ -175,50 +500,111 @@ func (c *Client) VoiceSearch(voiceReq VoiceRequest, partialTranscriptChan chan P
 		req.Header.Set(k, v)
 	}
 
-	if err != nil {
-		return "", err
+	audioStream := voiceReq.AudioStream
+	if voiceReq.BodyTee != nil {
+		audioStream = io.TeeReader(audioStream, voiceReq.BodyTee)
+	}
+	if req.Header.Get("Hound-Request-Info-Length") != "" {
+		// the RequestInfo goes in the body ahead of the audio, the server uses the
+		// Hound-Request-Info-Length header to know where the audio starts
+		req.Body = ioutil.NopCloser(io.MultiReader(req.Body, audioStream))
+	} else {
+		req.Body = ioutil.NopCloser(audioStream)
 	}
-	req.Body = ioutil.NopCloser(voiceReq.AudioStream)
 
-	if c.HttpClient == nil {
-		c.HttpClient = &http.Client{}
+	// abort the request when the stop channel is closed, or all of the Client's
+	// requests are canceled
+	if voiceReq.StopCh != nil {
+		var cancelStop context.CancelFunc
+		req, cancelStop = cancelOn(req, voiceReq.StopCh)
+		defer cancelStop()
+	}
+	req, cancel := cancelOn(req, c.getShared().canceled)
+	defer cancel()
+	abortedErr := func() error {
+		if voiceReq.stopped() {
+			return ErrStopped
+		}
+		return c.canceledErr()
 	}
 
 	// send the request
-	resp, err := c.HttpClient.Do(req)
+	resp, err := c.httpClient().Do(req)
+	c.countRequest(resp, err)
 	if err != nil {
-		return "", errors.New("failed to successfully run request: " + err.Error())
+		if abortErr := abortedErr(); abortErr != nil {
+			return "", VoiceIncomplete, abortErr
+		}
+		if offlineErr := offlineErr(err); offlineErr != nil {
+			return "", VoiceIncomplete, offlineErr
+		}
+		return "", VoiceIncomplete, errors.New("failed to successfully run request: " + err.Error())
 	}
+	defer resp.Body.Close()
 
-	if c.Verbose {
+	verbose := c.isVerbose(voiceReq.Verbose)
+	if verbose {
 		fmt.Println(resp.Proto, resp.StatusCode)
 		fmt.Println("Headers: ", resp.Header)
 	}
 
 	// partial transcript parsing
 
-	reader := bufio.NewReader(resp.Body)
+	reader := bufio.NewReader(c.limitResponse(resp.Body))
 	var line string
+	completion := VoiceIncomplete
+	prevSent := make(chan struct{})
+	close(prevSent)
 	for {
 		bytes, err := reader.ReadBytes('\n')
 		line = strings.TrimSpace(string(bytes))
-		if c.Verbose {
+		if verbose {
 			fmt.Println(line)
 		}
+		if err == ErrResponseTooLarge {
+			return "", completion, err
+		}
+		if abortErr := abortedErr(); err != nil && abortErr != nil {
+			return "", completion, abortErr
+		}
 		if err != nil {
 			if err != io.EOF {
 				fmt.Println(err)
-				return "", errors.New("error reading Houndify server response")
+				return "", completion, errors.New("error reading Houndify server response")
 			}
 			//EOF means this line must be the final response, done with partial transcripts
+			completion = VoiceEndedWithoutResult
+			if c.isVoiceSearchResult(line) {
+				completion = VoiceFinalResult
+			}
 			break
 		}
 		if line == "" {
 			continue
 		}
-		if _, convertErr := strconv.Atoi(line); convertErr == nil {
-			// this is an integer, so one of the ObjectByteCountPrefixes, skip it
-			continue
+		if byteCount, convertErr := strconv.Atoi(line); convertErr == nil {
+			// this is an integer, so one of the ObjectByteCountPrefixes. Read exactly that
+			// many bytes as the next message, as it may contain newlines of its own.
+			if byteCount < 0 {
+				continue
+			}
+			// the count comes from the server, so don't trust it to allocate the message
+			if c.MaxResponseBytes > 0 && int64(byteCount) > c.MaxResponseBytes {
+				return "", completion, ErrResponseTooLarge
+			}
+			var message strings.Builder
+			if _, err := io.CopyN(&message, reader, int64(byteCount)); err == ErrResponseTooLarge {
+				return "", completion, err
+			} else if abortErr := abortedErr(); err != nil && abortErr != nil {
+				return "", completion, abortErr
+			} else if err != nil {
+				fmt.Println(err)
+				return "", completion, errors.New("error reading Houndify server response")
+			}
+			line = message.String()
+			if verbose {
+				fmt.Println(line)
+			}
 		}
 		// attempt to parse incoming json into partial transcript
 		incoming := houndServerPartialTranscript{}
@@ -226,46 +612,484 @@ func (c *Client) VoiceSearch(voiceReq VoiceRequest, partialTranscriptChan chan P
 			fmt.Println("fail reading hound server message")
 			continue
 		}
-		if incoming.Format == "HoundVoiceQueryPartialTranscript" || incoming.Format == "SoundHoundVoiceSearchParialTranscript" {
+		if c.isPartialTranscriptFormat(incoming.Format) {
 			// convert from houndify server's struct to SDK's simplified struct
 			partialDuration, err := time.ParseDuration(fmt.Sprintf("%d", incoming.DurationMS) + "ms")
 			if err != nil {
 				fmt.Println("failed reading the time in partial transcript")
 				continue
 			}
-			partialChanWait.Add(1)
-			go func() {
-				partialTranscriptChan <- PartialTranscript{
-					Message:         incoming.PartialTranscript,
-					Duration:        partialDuration,
-					Done:            incoming.Done,
-					SafeToStopAudio: incoming.SafeToStopAudio,
+			// send without blocking the response from being read, but only after the
+			// previous partial transcript was sent so they stay in order
+			partial := PartialTranscript{
+				Duration:        partialDuration,
+				DurationMS:      incoming.DurationMS,
+				Done:            incoming.Done,
+				SafeToStopAudio: incoming.SafeToStopAudio,
+				Language:        incoming.Language,
+			}
+			if incoming.PartialTranscript != nil {
+				partial.Message = *incoming.PartialTranscript
+			}
+			if incoming.SafeToStopAudio != nil && *incoming.SafeToStopAudio {
+				// where speech ended, from the control message or else the audio heard so far
+				partial.SafeToStopOffset = partialDuration
+				if incoming.DurationMS == 0 {
+					partial.SafeToStopOffset = lastPartial.Duration
 				}
+			}
+			if incoming.PartialTranscript != nil {
+				lastPartial = partial
+			} else {
+				// a control message, e.g. only SafeToStopAudio, doesn't replace the transcript
+				// that the final partial transcript is made from
+				lastPartial.Done = partial.Done
+			}
+			partialChanWait.Add(1)
+			sent := make(chan struct{})
+			go func(prevSent <-chan struct{}) {
+				<-prevSent
+				partialTranscriptChan <- partial
+				close(sent)
 				partialChanWait.Done()
-			}()
+			}(prevSent)
+			prevSent = sent
 			continue
 		}
-		if incoming.Format == "SoundHoundVoiceSearchResult" {
+		if c.isResultFormat(incoming.Format) {
 			//this line is the final response, done with partial transcripts
+			completion = VoiceFinalResult
 			break
 		}
 	}
 
-	bodyStr := line
-	defer resp.Body.Close()
+	// the final partial transcript has the final result's transcription
+	if completion == VoiceFinalResult {
+		if response, err := ParseResponse(line); err == nil && response.Disambiguation != nil &&
+			len(response.Disambiguation.ChoiceData) > 0 {
+			lastPartial.Message = response.Disambiguation.ChoiceData[0].Transcription
+		}
+	}
+
+	bodyStr, err := c.applyResponseMiddleware(line)
+	if err != nil {
+		return "", completion, err
+	}
 
 	//don't try to parse out conversation state from a bad response
 	if resp.StatusCode >= 400 {
-		return bodyStr, errors.New("error response")
+		if err := c.checkClockSkew(resp); err != nil {
+			return bodyStr, completion, err
+		}
+		return bodyStr, completion, errors.New("error response")
+	}
+	if err := c.checkFormatVersion(bodyStr); err != nil {
+		return bodyStr, completion, err
+	}
+	if voiceReq.ExpectedAudioLength > 0 {
+		c.checkAudioLength(bodyStr, voiceReq.ExpectedAudioLength)
 	}
 	// update with new conversation state
+	if err := c.updateConversationState(bodyStr); err != nil {
+		return bodyStr, completion, err
+	}
+
+	succeeded = true
+	return bodyStr, completion, nil
+}
+
+// VoiceSearchInteractive sends an audio request like VoiceSearch, and lets the caller
+// pick which transcription was meant when the server returns a disambiguation.
+//
+// If the response has more than one disambiguation choice, onDisambiguation is called
+// with them and returns the index of the chosen one. If a choice other than the first
+// (which the server already responded to) is chosen, its transcription is sent as a
+// follow-up text query, with the conversation state from before the voice request,
+// and the follow-up's response is returned instead. Returning a negative index, or a
+// nil onDisambiguation, keeps the original response.
+//
+// The follow-up is sent to the text endpoint next to the voice request's URL, e.g.
+// https://example.com/v1/text for https://example.com/v1/audio, or to the default text
+// endpoint if the voice request's URL isn't set.
+func (c *Client) VoiceSearchInteractive(voiceReq VoiceRequest, onDisambiguation func(*HoundifyDisambiguation) int) (string, error) {
+	prevConvState := c.conversationState
+
+	partialTranscripts := make(chan PartialTranscript)
+	go func() {
+		for range partialTranscripts {
+		}
+	}()
+	serverResponse, err := c.VoiceSearch(voiceReq, partialTranscripts)
+	if err != nil {
+		return serverResponse, err
+	}
+
+	response, err := ParseResponse(serverResponse)
+	if err != nil {
+		return serverResponse, err
+	}
+	if onDisambiguation == nil || response.Disambiguation == nil || len(response.Disambiguation.ChoiceData) < 2 {
+		return serverResponse, nil
+	}
+	choice := onDisambiguation(response.Disambiguation)
+	if choice <= 0 {
+		return serverResponse, nil
+	}
+	if choice >= len(response.Disambiguation.ChoiceData) {
+		return serverResponse, errors.Errorf("disambiguation choice %d out of range", choice)
+	}
+
+	chosen := response.Disambiguation.ChoiceData[choice]
+	query := chosen.FixedTranscription
+	if query == "" {
+		query = chosen.Transcription
+	}
+	reqInfo := make(map[string]interface{})
+	for k, v := range voiceReq.RequestInfoFields {
+		reqInfo[k] = v
+	}
+	textReq := TextRequest{
+		Query:             query,
+		URL:               textURLForVoiceURL(voiceReq.URL),
+		UserID:            voiceReq.UserID,
+		RequestID:         newRequestID(),
+		RequestInfoFields: reqInfo,
+		Verbose:           voiceReq.Verbose,
+		headers:           voiceReq.headers,
+		ctx:               voiceReq.ctx,
+	}
+	// the follow-up replaces the voice request's turn in the conversation
 	if c.enableConversationState {
-		newConvState, err := parseConversationState(bodyStr)
-		if err != nil {
-			return bodyStr, errors.Wrap(err, "unable to parse new conversation state from response")
+		c.conversationState = prevConvState
+	}
+	return c.TextSearch(textReq)
+}
+
+// checkFormatVersion warns, or returns an error if StrictFormatVersion is set, if the
+// response's FormatVersion is outside the Client's expected range
+func (c *Client) checkFormatVersion(bodyStr string) error {
+	if c.MinFormatVersion == "" && c.MaxFormatVersion == "" {
+		return nil
+	}
+	incoming := houndServerMessage{}
+	if err := json.Unmarshal([]byte(bodyStr), &incoming); err != nil {
+		return nil
+	}
+	if incoming.Version == "" {
+		// nothing to check, so don't fail the request even if StrictFormatVersion is set
+		c.warnf("response has no format version to check")
+		return nil
+	}
+	var problem string
+	if c.MinFormatVersion != "" && compareFormatVersions(incoming.Version, c.MinFormatVersion) < 0 {
+		problem = fmt.Sprintf("response format version %q is older than %q", incoming.Version, c.MinFormatVersion)
+	} else if c.MaxFormatVersion != "" && compareFormatVersions(incoming.Version, c.MaxFormatVersion) > 0 {
+		problem = fmt.Sprintf("response format version %q is newer than %q", incoming.Version, c.MaxFormatVersion)
+	} else {
+		return nil
+	}
+	if c.StrictFormatVersion {
+		return errors.Wrap(ErrUnsupportedFormatVersion, problem)
+	}
+	c.warnf("%s", problem)
+	return nil
+}
+
+// checkClockSkew returns an error if an unauthorized response's Date is too far from
+// the local time, as the request was likely rejected because its timestamp was off
+func (c *Client) checkClockSkew(resp *http.Response) error {
+	if !c.DetectClockSkew || resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+	skew := c.now().Sub(serverTime)
+	if time.Duration(math.Abs(float64(skew))) < clockSkewTolerance {
+		return nil
+	}
+	return errors.Wrapf(ErrClockSkew, "local clock is %v off from the server's, sync the clock", skew.Round(time.Second))
+}
+
+// textURLForVoiceURL returns the URL of the text endpoint next to the voice endpoint at
+// voiceURL, by replacing the last element of its path with "text". An empty voiceURL
+// gives an empty URL, so the default text endpoint is used.
+func textURLForVoiceURL(voiceURL string) string {
+	if voiceURL == "" {
+		return ""
+	}
+	u, err := url.Parse(voiceURL)
+	if err != nil {
+		return ""
+	}
+	u.Path = path.Join(path.Dir(u.Path), "text")
+	u.RawQuery = ""
+	return u.String()
+}
+
+// isVoiceSearchResult reports whether a server message is the final voice search result
+func (c *Client) isVoiceSearchResult(message string) bool {
+	incoming := houndServerMessage{}
+	if err := json.Unmarshal([]byte(message), &incoming); err != nil {
+		return false
+	}
+	return c.isResultFormat(incoming.Format)
+}
+
+// isResultFormat reports whether messages with the given Format are final results
+func (c *Client) isResultFormat(format string) bool {
+	return formatIn(format, c.ResultFormats, DefaultResultFormats)
+}
+
+// isPartialTranscriptFormat reports whether messages with the given Format are partial
+// transcripts
+func (c *Client) isPartialTranscriptFormat(format string) bool {
+	return formatIn(format, c.PartialTranscriptFormats, DefaultPartialTranscriptFormats)
+}
+
+// formatIn reports whether format is one of formats, or of defaults if formats is empty
+func formatIn(format string, formats, defaults []string) bool {
+	if len(formats) == 0 {
+		formats = defaults
+	}
+	for _, f := range formats {
+		if format == f {
+			return true
 		}
-		c.conversationState = newConvState
 	}
+	return false
+}
 
-	return bodyStr, nil
+// updateConversationState parses the conversation state out of a successful server
+// response and stores it on the Client, if conversation state is enabled.
+func (c *Client) updateConversationState(bodyStr string) error {
+	if !c.enableConversationState {
+		return nil
+	}
+	newConvState, err := parseConversationState(bodyStr)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse new conversation state from response")
+	}
+	if c.ConversationStateTransform != nil {
+		newConvState = c.ConversationStateTransform(newConvState)
+	}
+	c.conversationState = newConvState
+	return nil
+}
+
+// checkAudioLength warns if the length of audio the server reports receiving differs
+// significantly from the expected length, which usually means the audio stream was
+// truncated.
+func (c *Client) checkAudioLength(bodyStr string, expected time.Duration) {
+	actual, err := ParseAudioLength(bodyStr)
+	if err != nil {
+		c.warnf("unable to check audio length: %v", err)
+		return
+	}
+	tolerance := time.Duration(float64(expected) * audioLengthTolerance)
+	if time.Duration(math.Abs(float64(actual-expected))) > tolerance {
+		c.warnf("server received %v of audio, expected %v", actual, expected)
+	}
+}
+
+// httpClient returns the http.Client used to send requests, with the Client's
+// CookieJar and ResponseHeaderTimeout if it has them
+func (c *Client) httpClient() *http.Client {
+	// the Client isn't modified, as it may be in use by concurrent requests
+	httpClient := c.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	var baseTransport *http.Transport
+	useTimeout := c.ResponseHeaderTimeout > 0 && httpClient.Transport == nil
+	if c.ResponseHeaderTimeout > 0 && httpClient.Transport != nil {
+		baseTransport, useTimeout = httpClient.Transport.(*http.Transport)
+	}
+	if c.CookieJar == nil && !useTimeout {
+		return httpClient
+	}
+	client := *httpClient
+	if c.CookieJar != nil {
+		client.Jar = c.CookieJar
+	}
+	if useTimeout {
+		client.Transport = c.getShared().headerTimeoutTransport(baseTransport, c.ResponseHeaderTimeout)
+	}
+	return &client
+}
+
+// headerTimeoutTransport returns a transport with the given ResponseHeaderTimeout,
+// reusing it between requests so connections can be reused. Other than the timeout
+// it's configured like base, or like http.DefaultTransport if base is nil.
+func (s *clientShared) headerTimeoutTransport(base *http.Transport, timeout time.Duration) *http.Transport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transport != nil && s.transportBase == base && s.transport.ResponseHeaderTimeout == timeout {
+		return s.transport
+	}
+	s.transportBase = base
+	if base == nil {
+		base = &http.Transport{Proxy: http.ProxyFromEnvironment}
+		if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+			base = defaultTransport
+		}
+	}
+	s.transport = copyTransport(base)
+	s.transport.ResponseHeaderTimeout = timeout
+	return s.transport
+}
+
+// copyTransport returns a new transport configured like t. A Transport can't be copied
+// by value as it holds its connections. Transport.Clone is used when the Go version
+// has it, as it also copies the fields newer than this code, such as the one enabling
+// HTTP/2.
+func copyTransport(t *http.Transport) *http.Transport {
+	if cloner, ok := interface{}(t).(interface{ Clone() *http.Transport }); ok {
+		return cloner.Clone()
+	}
+	return &http.Transport{
+		Proxy:                  t.Proxy,
+		DialContext:            t.DialContext,
+		Dial:                   t.Dial,
+		DialTLS:                t.DialTLS,
+		TLSClientConfig:        t.TLSClientConfig,
+		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
+		DisableKeepAlives:      t.DisableKeepAlives,
+		DisableCompression:     t.DisableCompression,
+		MaxIdleConns:           t.MaxIdleConns,
+		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:        t.MaxConnsPerHost,
+		IdleConnTimeout:        t.IdleConnTimeout,
+		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		TLSNextProto:           t.TLSNextProto,
+		ProxyConnectHeader:     t.ProxyConnectHeader,
+		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+}
+
+// voiceSemaphore returns a semaphore with max slots, reusing it between requests so the
+// limit is shared by all of them. It's only sized once, as replacing it would let
+// requests holding slots of the old one exceed the limit.
+func (s *clientShared) voiceSemaphore(max int) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.voiceSlots == nil {
+		s.voiceSlots = make(chan struct{}, max)
+	}
+	return s.voiceSlots
+}
+
+// acquireVoiceSlot takes one of the Client's MaxConcurrentVoice slots for a voice request,
+// waiting for one to be free unless RejectConcurrentVoice is set. The waiting is aborted
+// if the request is stopped or canceled. The returned func releases the slot.
+func (c *Client) acquireVoiceSlot(voiceReq *VoiceRequest) (func(), error) {
+	if c.MaxConcurrentVoice <= 0 {
+		return func() {}, nil
+	}
+	slots := c.getShared().voiceSemaphore(c.MaxConcurrentVoice)
+	release := func() {
+		<-slots
+	}
+	if c.RejectConcurrentVoice {
+		select {
+		case slots <- struct{}{}:
+			return release, nil
+		default:
+			return nil, ErrTooManyVoiceRequests
+		}
+	}
+
+	var ctxDone <-chan struct{}
+	if voiceReq.ctx != nil {
+		ctxDone = voiceReq.ctx.Done()
+	}
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-voiceReq.StopCh:
+		return nil, ErrStopped
+	case <-ctxDone:
+		return nil, voiceReq.ctx.Err()
+	case <-c.getShared().canceled:
+		return nil, c.canceledErr()
+	}
+}
+
+// limitResponse limits how much of the response body can be read to the Client's
+// MaxResponseBytes, if it has a limit
+func (c *Client) limitResponse(body io.Reader) io.Reader {
+	if c.MaxResponseBytes <= 0 {
+		return body
+	}
+	return &maxBytesReader{r: body, max: c.MaxResponseBytes}
+}
+
+// maxBytesReader reads from r until more than max bytes have been read, after which it
+// returns ErrResponseTooLarge
+type maxBytesReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (l *maxBytesReader) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, ErrResponseTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n - int(l.read-l.max), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+// applyResponseMiddleware runs the response body through the Client's
+// ResponseMiddleware, if it has one
+func (c *Client) applyResponseMiddleware(body string) (string, error) {
+	if c.ResponseMiddleware == nil {
+		return body, nil
+	}
+	body, err := c.ResponseMiddleware(body)
+	if err != nil {
+		return "", errors.Wrap(err, "response middleware failed")
+	}
+	return body, nil
+}
+
+// userID returns the UserID to send for a request, hashed if the Client has a
+// UserIDHasher
+func (c Client) userID(userID string) string {
+	if c.UserIDHasher != nil {
+		return c.UserIDHasher(userID)
+	}
+	return userID
+}
+
+// now returns the current time according to the Client's Clock
+func (c Client) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// isVerbose reports whether a request should be verbose, using the request's override
+// if it has one, or the Client's setting otherwise
+func (c *Client) isVerbose(override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return c.Verbose
+}
+
+// warnf logs a warning to the Client's Logger, or stdout if there isn't one
+func (c *Client) warnf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf("warning: "+format, v...)
+		return
+	}
+	fmt.Printf("warning: "+format+"\n", v...)
 }
@@ -0,0 +1,937 @@
+package houndify_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// A minimal successful server response carrying a conversation state
+const testServerResponse = `{
+	"Format": "SoundHoundVoiceSearchResult",
+	"FormatVersion": "1.0",
+	"Status": "OK",
+	"NumToReturn": 1,
+	"AllResults": [
+		{
+			"WrittenResponse": "It is noon.",
+			"WrittenResponseLong": "It is twelve o'clock noon.",
+			"ConversationState": {"ConversationStateTime": 1234}
+		}
+	]
+}`
+
+// Return a mock response with the given status code and body
+func NewTestResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Header:     make(http.Header),
+	}
+}
+
+// Tests that Client.ConversationStateTransform is applied to the state before storing
+func TestConversationStateTransform(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.EnableConversationState()
+	houndifyClient.ConversationStateTransform = func(state interface{}) interface{} {
+		s := state.(map[string]interface{})
+		s["TurnCount"] = 1
+		return s
+	}
+
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+
+	state := houndifyClient.GetConversationState().(map[string]interface{})
+	assert.Equal(t, state["TurnCount"], 1)
+	assert.Equal(t, state["ConversationStateTime"], float64(1234))
+}
+
+// Tests that an invalid UTF-8 query is rejected before being sent
+func TestTextSearchInvalidUTF8(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		t.Fatal("request with invalid UTF-8 query should not be sent")
+		return nil
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.ValidateQueryUTF8 = true
+
+	textReq := NewTestTextRequest()
+	textReq.Query = "what is \xff\xfe the time"
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.Equal(t, err, ErrInvalidUTF8)
+}
+
+// Return a voice response body made of the given server messages, each on its own line
+// prefixed with its byte count like the Hound server does
+func NewTestVoiceResponseBody(messages ...string) string {
+	var body strings.Builder
+	for _, msg := range messages {
+		body.WriteString(strconv.Itoa(len(msg)) + "\n" + msg + "\n")
+	}
+	return body.String()
+}
+
+// Drain the partial transcript channel until it's closed
+func DiscardPartialTranscripts(partials chan PartialTranscript) {
+	go func() {
+		for range partials {
+		}
+	}()
+}
+
+// Tests that a warning is logged when the server received less audio than expected
+func TestVoiceSearchAudioLengthMismatch(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AudioLength":1.5,"AllResults":[{}]}`,
+		))
+	})
+
+	var logs bytes.Buffer
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.Logger = log.New(&logs, "", 0)
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	voiceReq.ExpectedAudioLength = 3 * time.Second
+
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(logs.String(), "server received 1.5s of audio, expected 3s"), logs.String())
+
+	// a matching length shouldn't warn
+	logs.Reset()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	voiceReq.ExpectedAudioLength = 1500 * time.Millisecond
+	partials = make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err = houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Equal(t, logs.String(), "")
+}
+
+// Tests that the audio sent in the request body is teed to VoiceRequest.BodyTee
+func TestVoiceSearchBodyTee(t *testing.T) {
+	audio := []byte("RIFF fake audio data, long enough to be read in more than one go")
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		sent, err := ioutil.ReadAll(req.Body)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, sent, audio)
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+		))
+	})
+
+	var teed bytes.Buffer
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(audio)
+	voiceReq.BodyTee = &teed
+
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, teed.Bytes(), audio)
+}
+
+// Run f and return everything it printed to stdout
+func CaptureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	assert.NilError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		output <- string(out)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+// Tests that TextRequest.Verbose overrides the Client's Verbose setting
+func TestTextSearchVerboseOverride(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	})
+	verbose, quiet := true, false
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	textReq := NewTestTextRequest()
+	textReq.Verbose = &verbose
+	output := CaptureStdout(t, func() {
+		_, err := houndifyClient.TextSearch(textReq)
+		assert.NilError(t, err)
+	})
+	assert.Assert(t, strings.Contains(output, "SoundHoundVoiceSearchResult"), output)
+	assert.Equal(t, houndifyClient.Verbose, false)
+
+	houndifyClient.Verbose = true
+	textReq.Verbose = &quiet
+	output = CaptureStdout(t, func() {
+		_, err := houndifyClient.TextSearch(textReq)
+		assert.NilError(t, err)
+	})
+	assert.Equal(t, output, "")
+}
+
+// Tests that a large final result containing newlines is read whole using its byte
+// count prefix, even when the response body arrives in small pieces
+func TestVoiceSearchLargeFinalResult(t *testing.T) {
+	writtenResponse := strings.Repeat("All work and no play makes Jack a dull boy. ", 2000)
+	finalResult := `{
+		"Format": "SoundHoundVoiceSearchResult",
+		"Status": "OK",
+		"NumToReturn": 1,
+		"AllResults": [
+			{
+				"WrittenResponseLong": "` + writtenResponse + `"
+			}
+		]
+	}`
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		body := NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"all work","DurationMS":500}`,
+			finalResult,
+		)
+		resp := NewTestResponse(200, "")
+		resp.Body = ioutil.NopCloser(iotest.HalfReader(strings.NewReader(body)))
+		return resp
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	serverResponse, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Equal(t, serverResponse, finalResult)
+
+	parsed, err := ParseWrittenResponse(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, parsed, writtenResponse)
+}
+
+// Tests that reading a voice response larger than Client.MaxResponseBytes is aborted,
+// and the partial transcript channel is closed
+func TestVoiceSearchMaxResponseBytes(t *testing.T) {
+	partial := `{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"la la la","DurationMS":500}`
+	messages := make([]string, 1000)
+	for i := range messages {
+		messages[i] = partial
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(messages...))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.MaxResponseBytes = 4096
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+
+	partials := make(chan PartialTranscript)
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range partials {
+			count++
+		}
+		received <- count
+	}()
+
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.Equal(t, err, ErrResponseTooLarge)
+
+	select {
+	case count := <-received:
+		assert.Assert(t, count < len(messages))
+	case <-time.After(time.Second):
+		t.Fatal("partial transcript channel wasn't closed")
+	}
+}
+
+// Tests that a byte count prefix larger than Client.MaxResponseBytes is aborted before
+// the message is read
+func TestVoiceSearchMaxResponseBytesPrefix(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, "4000000000\n"+testServerResponse)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.MaxResponseBytes = 1024
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.Equal(t, err, ErrResponseTooLarge)
+}
+
+// Tests that reading a text response larger than Client.MaxResponseBytes is aborted
+func TestTextSearchMaxResponseBytes(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.MaxResponseBytes = 64
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.Equal(t, err, ErrResponseTooLarge)
+
+	houndifyClient.MaxResponseBytes = int64(len(testServerResponse))
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+}
+
+// Tests that the disambiguation choice picked in VoiceSearchInteractive is sent as a
+// follow-up text query, whose response is returned
+func TestVoiceSearchInteractive(t *testing.T) {
+	requests := 0
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		if requests == 1 {
+			assert.Equal(t, req.URL.Path, "/houndify/v1/voice")
+			return NewTestResponse(200, NewTestVoiceResponseBody(
+				`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,`+
+					`"AllResults":[{"WrittenResponseLong":"Here is the weather in Austin."}],`+
+					`"Disambiguation":{"NumToShow":2,"ChoiceData":[`+
+					`{"Transcription":"weather in austin","ConfidenceScore":0.6,"FixedTranscription":"weather in Austin"},`+
+					`{"Transcription":"weather in boston","ConfidenceScore":0.4,"FixedTranscription":"weather in Boston"}]}}`,
+			))
+		}
+		// sent to the text endpoint next to the voice one
+		assert.Equal(t, req.URL.Host, "proxy.test.com")
+		assert.Equal(t, req.URL.Path, "/houndify/v1/text")
+		assert.Equal(t, req.URL.Query().Get("query"), "weather in Boston")
+		return NewTestResponse(200, `{"Status":"OK","NumToReturn":1,"AllResults":[{"WrittenResponseLong":"Here is the weather in Boston."}]}`)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.URL = "http://proxy.test.com/houndify/v1/voice"
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+
+	var choices []HoundifyDisambiguationChoice
+	serverResponse, err := houndifyClient.VoiceSearchInteractive(voiceReq, func(d *HoundifyDisambiguation) int {
+		choices = d.ChoiceData
+		return 1
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, requests, 2)
+	assert.Equal(t, len(choices), 2)
+
+	writtenResponse, err := ParseWrittenResponse(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, writtenResponse, "Here is the weather in Boston.")
+}
+
+// Tests that VoiceSearchInteractive without a disambiguation callback keeps the original
+// response
+func TestVoiceSearchInteractiveNilCallback(t *testing.T) {
+	requests := 0
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,`+
+				`"AllResults":[{"WrittenResponseLong":"Here is the weather in Austin."}],`+
+				`"Disambiguation":{"NumToShow":2,"ChoiceData":[`+
+				`{"Transcription":"weather in austin"},{"Transcription":"weather in boston"}]}}`,
+		))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	serverResponse, err := houndifyClient.VoiceSearchInteractive(voiceReq, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, requests, 1)
+
+	writtenResponse, err := ParseWrittenResponse(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, writtenResponse, "Here is the weather in Austin.")
+}
+
+// Tests that a cookie set by one response is sent with the next request when
+// Client.CookieJar is set
+func TestCookieJar(t *testing.T) {
+	requests := 0
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		resp := NewTestResponse(200, testServerResponse)
+		if requests == 1 {
+			_, err := req.Cookie("session")
+			assert.Equal(t, err, http.ErrNoCookie)
+			resp.Header.Set("Set-Cookie", "session=sticky123; Path=/")
+		} else {
+			cookie, err := req.Cookie("session")
+			assert.NilError(t, err)
+			assert.Equal(t, cookie.Value, "sticky123")
+		}
+		return resp
+	})
+
+	jar, err := cookiejar.New(nil)
+	assert.NilError(t, err)
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.CookieJar = jar
+
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Equal(t, requests, 2)
+}
+
+// Tests that Client.ResponseHeaderTimeout fails a request to a server that is slow to
+// respond, but not one that responds promptly
+func TestResponseHeaderTimeout(t *testing.T) {
+	delay := 500 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow/v1/text" {
+			time.Sleep(delay)
+		}
+		w.Write([]byte(testServerResponse))
+	}))
+	defer server.Close()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.ResponseHeaderTimeout = 100 * time.Millisecond
+
+	textReq := NewTestTextRequest()
+	textReq.URL = server.URL + "/slow/v1/text"
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+
+	textReq.URL = server.URL + "/v1/text"
+	_, err = houndifyClient.TextSearch(textReq)
+	assert.NilError(t, err)
+}
+
+// Tests that Client.ResponseHeaderTimeout also applies when the HttpClient has its own
+// *http.Transport, without changing that Transport
+func TestResponseHeaderTimeoutCustomTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Write([]byte(testServerResponse))
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{MaxIdleConnsPerHost: 4}
+	houndifyClient := NewTestHoundifyClient(&http.Client{Transport: transport})
+	houndifyClient.ResponseHeaderTimeout = 100 * time.Millisecond
+
+	textReq := NewTestTextRequest()
+	textReq.URL = server.URL + "/v1/text"
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+	assert.Equal(t, transport.ResponseHeaderTimeout, time.Duration(0))
+}
+
+// Tests that Client.ResponseHeaderTimeout without a custom transport keeps the
+// configuration of http.DefaultTransport, here trusting a test TLS server
+func TestResponseHeaderTimeoutDefaultTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testServerResponse))
+	}))
+	defer server.Close()
+
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.ResponseHeaderTimeout = time.Second
+
+	textReq := NewTestTextRequest()
+	textReq.URL = server.URL + "/v1/text"
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.NilError(t, err)
+}
+
+// Tests that closing VoiceRequest.StopCh mid-stream aborts the request promptly
+func TestVoiceSearchStopCh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what","DurationMS":500}`,
+		)))
+		w.(http.Flusher).Flush()
+		// keep streaming until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	stop := make(chan struct{})
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.URL = server.URL + "/v1/voice"
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	voiceReq.StopCh = stop
+
+	partials := make(chan PartialTranscript)
+	go func() {
+		<-partials
+		close(stop)
+		for range partials {
+		}
+	}()
+
+	start := time.Now()
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.Equal(t, err, ErrStopped)
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+}
+
+// Tests that Client.CancelAll aborts in-flight requests with a canceled error
+func TestCancelAll(t *testing.T) {
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		// hang until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	textReq := NewTestTextRequest()
+	textReq.URL = server.URL + "/v1/text"
+
+	go func() {
+		<-received
+		houndifyClient.CancelAll()
+	}()
+
+	start := time.Now()
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.Assert(t, errors.Cause(err) == context.Canceled, "got %v", err)
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+
+	// the client shouldn't be reused, new requests are canceled too
+	_, err = houndifyClient.TextSearch(textReq)
+	assert.Assert(t, errors.Cause(err) == context.Canceled, "got %v", err)
+}
+
+// Tests that Client.ResponseMiddleware transforms the body returned by both searches
+func TestResponseMiddleware(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/v1/voice" {
+			return NewTestResponse(200, NewTestVoiceResponseBody(
+				`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{"WrittenResponseLong":"Your PIN is 1234."}]}`,
+			))
+		}
+		return NewTestResponse(200, `{"Status":"OK","NumToReturn":1,"AllResults":[{"WrittenResponseLong":"Your PIN is 1234."}]}`)
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.ResponseMiddleware = func(body string) (string, error) {
+		return strings.Replace(body, "1234", "****", -1), nil
+	}
+
+	serverResponse, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	writtenResponse, err := ParseWrittenResponse(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, writtenResponse, "Your PIN is ****.")
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	serverResponse, err = houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	writtenResponse, err = ParseWrittenResponse(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, writtenResponse, "Your PIN is ****.")
+}
+
+// Tests that VoiceSearchWithCompletion tells a stream that ended with the final result
+// apart from one that ended without it
+func TestVoiceSearchWithCompletion(t *testing.T) {
+	partial := `{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what","DurationMS":500}`
+	result := `{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`
+
+	for _, test := range []struct {
+		body       string
+		completion VoiceCompletion
+	}{
+		{NewTestVoiceResponseBody(partial, result), VoiceFinalResult},
+		{NewTestVoiceResponseBody(partial, partial), VoiceEndedWithoutResult},
+	} {
+		mockClient := NewTestClient(func(req *http.Request) *http.Response {
+			return NewTestResponse(200, test.body)
+		})
+
+		houndifyClient := NewTestHoundifyClient(mockClient)
+		voiceReq := NewTestVoiceRequest()
+		voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+		partials := make(chan PartialTranscript)
+		DiscardPartialTranscripts(partials)
+		_, completion, _ := houndifyClient.VoiceSearchWithCompletion(voiceReq, partials)
+		assert.Equal(t, completion, test.completion)
+	}
+}
+
+// Tests that messages with a custom Format are recognized as the final result
+func TestResultFormats(t *testing.T) {
+	result := `{"Format":"SoundHoundVoiceSearchResultV2","Status":"OK","NumToReturn":1,"AllResults":[{}]}`
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what","DurationMS":500}`,
+			result,
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"trailing","DurationMS":900}`,
+		))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.ResultFormats = []string{"SoundHoundVoiceSearchResultV2"}
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	serverResponse, completion, err := houndifyClient.VoiceSearchWithCompletion(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Equal(t, completion, VoiceFinalResult)
+	assert.Equal(t, serverResponse, result)
+}
+
+// Tests that an unauthorized response from a server whose clock is far from the local
+// one returns ErrClockSkew
+func TestDetectClockSkew(t *testing.T) {
+	now := time.Unix(1565000000, 0)
+	serverTime := now.Add(-time.Hour)
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		resp := NewTestResponse(401, `{"Status":"Error","ErrorMessage":"Authentication failed"}`)
+		resp.Header.Set("Date", serverTime.UTC().Format(http.TimeFormat))
+		return resp
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.DetectClockSkew = true
+	houndifyClient.Clock = func() time.Time { return now }
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.Equal(t, errors.Cause(err), ErrClockSkew)
+	assert.ErrorContains(t, err, "local clock is 1h0m0s off")
+
+	// without skew it's just an error response
+	serverTime = now
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.Equal(t, err.Error(), "error response")
+}
+
+// Tests that responses with a FormatVersion outside the Client's window warn, or fail
+// when StrictFormatVersion is set, and ones inside it don't
+func TestFormatVersionWindow(t *testing.T) {
+	// testServerResponse has FormatVersion 1.0
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	})
+
+	var logs bytes.Buffer
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.Logger = log.New(&logs, "", 0)
+
+	// inside the window
+	houndifyClient.MinFormatVersion = "1.0"
+	houndifyClient.MaxFormatVersion = "1.2"
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Equal(t, logs.String(), "")
+
+	// outside the window, warning
+	houndifyClient.MinFormatVersion = "1.1"
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(logs.String(), `response format version "1.0" is older than "1.1"`), logs.String())
+
+	// outside the window, error
+	logs.Reset()
+	houndifyClient.MinFormatVersion = ""
+	houndifyClient.MaxFormatVersion = "0.9"
+	houndifyClient.StrictFormatVersion = true
+	_, err = houndifyClient.TextSearch(NewTestTextRequest())
+	assert.Equal(t, errors.Cause(err), ErrUnsupportedFormatVersion)
+	assert.Equal(t, logs.String(), "")
+}
+
+// Tests that a response without a FormatVersion only warns, even with
+// StrictFormatVersion
+func TestFormatVersionMissing(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, `{"Status":"OK","NumToReturn":1,"AllResults":[{"WrittenResponseLong":"It is noon."}]}`)
+	})
+
+	var logs bytes.Buffer
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.Logger = log.New(&logs, "", 0)
+	houndifyClient.MinFormatVersion = "1.0"
+	houndifyClient.StrictFormatVersion = true
+
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(logs.String(), "response has no format version"), logs.String())
+}
+
+// Tests getting the conversation state as a map, for a map and non-map state
+func TestConversationStateMap(t *testing.T) {
+	houndifyClient := NewTestHoundifyClient(nil)
+
+	houndifyClient.SetConversationState(map[string]interface{}{"ConversationStateTime": 1234})
+	state, ok := houndifyClient.ConversationStateMap()
+	assert.Assert(t, ok)
+	assert.Equal(t, state["ConversationStateTime"], 1234)
+
+	houndifyClient.SetConversationState("not a map")
+	state, ok = houndifyClient.ConversationStateMap()
+	assert.Assert(t, !ok)
+	assert.Assert(t, state == nil)
+
+	houndifyClient.ClearConversationState()
+	_, ok = houndifyClient.ConversationStateMap()
+	assert.Assert(t, !ok)
+}
+
+// Run a basic text search with houndifyClient, for the benchmarks
+func runTestTextSearch(t testing.TB, houndifyClient *Client) {
+	_, err := houndifyClient.TextSearch(NewTestTextRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that stateless text searches don't send a conversation state, or add one to the
+// request's fields
+func TestTextSearchStateless(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Assert(t, !strings.Contains(req.Header.Get("Hound-Request-Info"), "ConversationState"))
+		return NewTestResponse(200, testServerResponse)
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.DisableConversationState()
+
+	textReq := NewTestTextRequest()
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.NilError(t, err)
+	_, ok := textReq.RequestInfoFields["ConversationState"]
+	assert.Assert(t, !ok)
+}
+
+func BenchmarkTextSearchStateless(b *testing.B) {
+	houndifyClient := NewTestHoundifyClient(NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	}))
+	houndifyClient.DisableConversationState()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		runTestTextSearch(b, &houndifyClient)
+	}
+}
+
+func BenchmarkTextSearchConversationState(b *testing.B) {
+	houndifyClient := NewTestHoundifyClient(NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	}))
+	houndifyClient.EnableConversationState()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		runTestTextSearch(b, &houndifyClient)
+	}
+}
+
+// Tests that formatting a Client doesn't leak its credentials
+func TestClientStringMasksKey(t *testing.T) {
+	houndifyClient := NewTestHoundifyClient(nil)
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		for _, formatted := range []string{
+			fmt.Sprintf(format, houndifyClient),
+			fmt.Sprintf(format, &houndifyClient),
+		} {
+			assert.Assert(t, !strings.Contains(formatted, houndifyClient.ClientKey), "%s leaked the key: %s", format, formatted)
+			assert.Assert(t, !strings.Contains(formatted, houndifyClient.ClientID), "%s leaked the ID: %s", format, formatted)
+		}
+	}
+	assert.Equal(t, houndifyClient.String(), `houndify.Client{ClientID: "9M22****", ClientKey: "****"}`)
+}
+
+// Tests that Warmup makes a request to the Hound server
+func TestWarmup(t *testing.T) {
+	var warmupReq *http.Request
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		warmupReq = req
+		return NewTestResponse(405, "")
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	assert.NilError(t, houndifyClient.Warmup(context.Background()))
+	assert.Assert(t, warmupReq != nil)
+	assert.Equal(t, warmupReq.Method, "HEAD")
+	assert.Equal(t, warmupReq.URL.Host, "api.houndify.com:443")
+	assert.Equal(t, warmupReq.URL.Scheme, "https")
+}
+
+// Return a Client whose voice requests block until finish is closed, and a channel that
+// receives a value as each of them reaches the server
+func NewTestBlockingVoiceClient(finish <-chan struct{}) (Client, <-chan struct{}) {
+	started := make(chan struct{}, 16)
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		started <- struct{}{}
+		<-finish
+		return NewTestResponse(200, NewTestVoiceResponseBody(testServerResponse))
+	})
+	return NewTestHoundifyClient(mockClient), started
+}
+
+// Send a basic voice request with houndifyClient, discarding its partial transcripts
+func testVoiceSearch(houndifyClient *Client) error {
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	return err
+}
+
+// Tests that no more than MaxConcurrentVoice voice requests are in flight at once
+func TestMaxConcurrentVoice(t *testing.T) {
+	finish := make(chan struct{})
+	houndifyClient, started := NewTestBlockingVoiceClient(finish)
+	houndifyClient.MaxConcurrentVoice = 2
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- testVoiceSearch(&houndifyClient)
+		}()
+	}
+	// the requests in flight hold their slots until they're let finish, so no others
+	// reach the server
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("more than MaxConcurrentVoice requests in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(finish)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+}
+
+// Tests that changing MaxConcurrentVoice after the first request doesn't raise the limit
+func TestMaxConcurrentVoiceFixed(t *testing.T) {
+	finish := make(chan struct{})
+	houndifyClient, started := NewTestBlockingVoiceClient(finish)
+	houndifyClient.MaxConcurrentVoice = 1
+	houndifyClient.RejectConcurrentVoice = true
+
+	firstErr := make(chan error)
+	go func() {
+		firstErr <- testVoiceSearch(&houndifyClient)
+	}()
+	<-started
+
+	houndifyClient.MaxConcurrentVoice = 2
+	assert.Equal(t, testVoiceSearch(&houndifyClient), ErrTooManyVoiceRequests)
+	close(finish)
+	assert.NilError(t, <-firstErr)
+}
+
+// Tests that concurrent voice requests can share a Client without its own HttpClient,
+// which is checked for data races when the tests are run with -race
+func TestConcurrentVoiceSearchDefaultHttpClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte(NewTestVoiceResponseBody(testServerResponse)))
+	}))
+	defer server.Close()
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.MaxConcurrentVoice = 2
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			voiceReq := NewTestVoiceRequest()
+			voiceReq.URL = server.URL + "/v1/voice"
+			voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+			partials := make(chan PartialTranscript)
+			DiscardPartialTranscripts(partials)
+			_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+	assert.Assert(t, houndifyClient.HttpClient == nil)
+}
+
+// Tests that voice requests beyond MaxConcurrentVoice fail when RejectConcurrentVoice is set
+func TestMaxConcurrentVoiceReject(t *testing.T) {
+	finish := make(chan struct{})
+	houndifyClient, started := NewTestBlockingVoiceClient(finish)
+	houndifyClient.MaxConcurrentVoice = 1
+	houndifyClient.RejectConcurrentVoice = true
+
+	firstErr := make(chan error)
+	go func() {
+		firstErr <- testVoiceSearch(&houndifyClient)
+	}()
+	<-started
+
+	assert.Equal(t, testVoiceSearch(&houndifyClient), ErrTooManyVoiceRequests)
+	close(finish)
+	assert.NilError(t, <-firstErr)
+
+	// the slot is free again
+	assert.NilError(t, testVoiceSearch(&houndifyClient))
+}
@@ -0,0 +1,75 @@
+package houndify
+
+import (
+	"errors"
+	"strings"
+)
+
+// A Language is an input language supported by Houndify, identified by both the English
+// name and IETF tag that are sent in the RequestInfo.
+type Language struct {
+	// Sent as the InputLanguageEnglishName RequestInfo field
+	EnglishName string
+	// Sent as the InputLanguageIETFTag RequestInfo field
+	IETFTag string
+}
+
+// Common input languages
+var (
+	LangEnglishUS       = Language{EnglishName: "English", IETFTag: "en-US"}
+	LangEnglishGB       = Language{EnglishName: "English", IETFTag: "en-GB"}
+	LangSpanishES       = Language{EnglishName: "Spanish", IETFTag: "es-ES"}
+	LangSpanishMX       = Language{EnglishName: "Spanish", IETFTag: "es-MX"}
+	LangFrenchFR        = Language{EnglishName: "French", IETFTag: "fr-FR"}
+	LangGermanDE        = Language{EnglishName: "German", IETFTag: "de-DE"}
+	LangItalianIT       = Language{EnglishName: "Italian", IETFTag: "it-IT"}
+	LangJapaneseJP      = Language{EnglishName: "Japanese", IETFTag: "ja-JP"}
+	LangKoreanKR        = Language{EnglishName: "Korean", IETFTag: "ko-KR"}
+	LangMandarinChinese = Language{EnglishName: "Mandarin Chinese", IETFTag: "zh-CN"}
+)
+
+var knownLanguages = []Language{
+	LangEnglishUS,
+	LangEnglishGB,
+	LangSpanishES,
+	LangSpanishMX,
+	LangFrenchFR,
+	LangGermanDE,
+	LangItalianIT,
+	LangJapaneseJP,
+	LangKoreanKR,
+	LangMandarinChinese,
+}
+
+// LanguageForTag returns the known Language with the given IETF tag. The tag is matched
+// case insensitively, so "en-us" returns LangEnglishUS with its tag correctly cased.
+func LanguageForTag(tag string) (Language, error) {
+	for _, lang := range knownLanguages {
+		if strings.EqualFold(lang.IETFTag, tag) {
+			return lang, nil
+		}
+	}
+	return Language{}, errors.New("unknown language tag: " + tag)
+}
+
+// Validate returns an error if the Language isn't one of the known languages, or if
+// its English name and IETF tag don't match each other.
+func (l Language) Validate() error {
+	known, err := LanguageForTag(l.IETFTag)
+	if err != nil {
+		return err
+	}
+	if known.IETFTag != l.IETFTag {
+		return errors.New("language tag " + l.IETFTag + " should be written as " + known.IETFTag)
+	}
+	if known.EnglishName != l.EnglishName {
+		return errors.New("language tag " + l.IETFTag + " is " + known.EnglishName + ", not " + l.EnglishName)
+	}
+	return nil
+}
+
+// setLanguage sets the language fields in a RequestInfo
+func setLanguage(reqInfo map[string]interface{}, lang Language) {
+	reqInfo["InputLanguageEnglishName"] = lang.EnglishName
+	reqInfo["InputLanguageIETFTag"] = lang.IETFTag
+}
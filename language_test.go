@@ -0,0 +1,32 @@
+package houndify_test
+
+import (
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"testing"
+)
+
+// Tests that setting a known language sets both language fields, and the headers
+func TestSetLanguage(t *testing.T) {
+	textReq := NewTestTextRequest()
+	textReq.SetLanguage(LangEnglishUS)
+	assert.Equal(t, textReq.RequestInfoFields["InputLanguageEnglishName"], "English")
+	assert.Equal(t, textReq.RequestInfoFields["InputLanguageIETFTag"], "en-US")
+
+	req, err := BuildRequest(&textReq, NewTestHoundifyClient(nil))
+	assert.NilError(t, err)
+	assert.Equal(t, req.Header.Get("Hound-Input-Language-English-Name"), "English")
+	assert.Equal(t, req.Header.Get("Hound-Input-Language-IETF-Tag"), "en-US")
+}
+
+// Tests validating and looking up languages
+func TestLanguageValidate(t *testing.T) {
+	assert.NilError(t, LangJapaneseJP.Validate())
+	assert.ErrorContains(t, Language{EnglishName: "English", IETFTag: "en-us"}.Validate(), "en-US")
+	assert.ErrorContains(t, Language{EnglishName: "French", IETFTag: "en-US"}.Validate(), "English")
+	assert.ErrorContains(t, Language{EnglishName: "Klingon", IETFTag: "tlh"}.Validate(), "unknown")
+
+	lang, err := LanguageForTag("en-us")
+	assert.NilError(t, err)
+	assert.Equal(t, lang, LangEnglishUS)
+}
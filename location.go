@@ -0,0 +1,31 @@
+package houndify
+
+import (
+	"time"
+)
+
+// A Location is the position of the user making a request, used by the server for
+// location based queries such as "what's the weather like".
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// The accuracy of the position in meters, optional
+	HorizontalAccuracy float64
+	// When the position was measured. If zero, it is set to the time the request is
+	// sent, according to the Client's Clock.
+	PositionTime time.Time
+}
+
+// setLocation sets the location fields in a RequestInfo
+func setLocation(reqInfo map[string]interface{}, loc Location) {
+	reqInfo["Latitude"] = loc.Latitude
+	reqInfo["Longitude"] = loc.Longitude
+	if loc.HorizontalAccuracy > 0 {
+		reqInfo["PositionHorizontalAccuracy"] = loc.HorizontalAccuracy
+	}
+	if !loc.PositionTime.IsZero() {
+		reqInfo["PositionTime"] = loc.PositionTime.Unix()
+	} else {
+		delete(reqInfo, "PositionTime")
+	}
+}
@@ -0,0 +1,57 @@
+package houndify_test
+
+import (
+	"encoding/json"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+// Tests that the PositionTime of a location defaults to the Client's current time, and
+// an explicit PositionTime is kept
+func TestSetLocationPositionTime(t *testing.T) {
+	now := time.Unix(1565000000, 0)
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.Clock = func() time.Time { return now }
+
+	for _, test := range []struct {
+		positionTime time.Time
+		expected     int64
+	}{
+		{time.Time{}, now.Unix()},
+		{now.Add(-time.Minute), now.Unix() - 60},
+	} {
+		textReq := NewTestTextRequest()
+		textReq.SetLocation(Location{Latitude: 43.65, Longitude: -79.38, PositionTime: test.positionTime})
+		req, err := BuildRequest(&textReq, houndifyClient)
+		assert.NilError(t, err)
+
+		reqInfo := make(map[string]interface{})
+		assert.NilError(t, json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo))
+		assert.Equal(t, reqInfo["Latitude"], 43.65)
+		assert.Equal(t, reqInfo["Longitude"], -79.38)
+		assert.Equal(t, reqInfo["PositionTime"], float64(test.expected))
+	}
+}
+
+// Tests that a reused request gets a new default PositionTime each time it's sent
+func TestSetLocationPositionTimeReused(t *testing.T) {
+	now := time.Unix(1565000000, 0)
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.Clock = func() time.Time { return now }
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.SetLocation(Location{Latitude: 43.65, Longitude: -79.38})
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Hour)
+		req, err := BuildRequest(&voiceReq, houndifyClient)
+		assert.NilError(t, err)
+
+		reqInfo := make(map[string]interface{})
+		assert.NilError(t, json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo))
+		assert.Equal(t, reqInfo["PositionTime"], float64(now.Unix()))
+	}
+	_, ok := voiceReq.RequestInfoFields["PositionTime"]
+	assert.Assert(t, !ok)
+}
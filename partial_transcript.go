@@ -9,7 +9,17 @@ type PartialTranscript struct {
 	Message string
 	// Length of audio this partial transcript applies to
 	Duration time.Duration
-	// If this is the last partial transcript
+	// Length of audio this partial transcript applies to in milliseconds, as sent by
+	// the server
+	DurationMS int64
+	// If this is the last partial transcript. When a voice search succeeds, the last one
+	// sent on its channel always has Done set, even if the server didn't send one. When
+	// it fails, no Done partial transcript is made up for the incomplete transcript.
 	Done            bool
 	SafeToStopAudio *bool
+	// When SafeToStopAudio is set, the offset into the audio at which the server
+	// considered the speech complete, e.g. for trimming recordings
+	SafeToStopOffset time.Duration
+	// The language detected in the audio, if the server provides it
+	Language string
 }
@@ -0,0 +1,181 @@
+package houndify_test
+
+import (
+	"bytes"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Return the partial transcripts received from a voice search whose response is made of
+// the given server messages
+func VoiceSearchPartialTranscripts(t *testing.T, houndifyClient Client, messages ...string) []PartialTranscript {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(messages...))
+	})
+	houndifyClient.HttpClient = mockClient
+
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+	partials := make(chan PartialTranscript)
+	received := make(chan []PartialTranscript)
+	go func() {
+		var all []PartialTranscript
+		for partial := range partials {
+			all = append(all, partial)
+		}
+		received <- all
+	}()
+
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	return <-received
+}
+
+// Tests that a partial transcript has both its Duration and raw DurationMS
+func TestPartialTranscriptDurationMS(t *testing.T) {
+	partials := VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":1500}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+
+	assert.Equal(t, len(partials), 2)
+	assert.Equal(t, partials[0].DurationMS, int64(1500))
+	assert.Equal(t, partials[0].Duration, 1500*time.Millisecond)
+	assert.Equal(t, partials[0].Duration, time.Duration(partials[0].DurationMS)*time.Millisecond)
+}
+
+// Tests that messages with a custom Format are recognized as partial transcripts
+func TestPartialTranscriptFormats(t *testing.T) {
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.PartialTranscriptFormats = []string{"HoundVoiceQueryPartialTranscriptV2"}
+	partials := VoiceSearchPartialTranscripts(t, houndifyClient,
+		`{"Format":"HoundVoiceQueryPartialTranscriptV2","PartialTranscript":"what time"}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what"}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+
+	// only the custom format is recognized, followed by the final partial transcript
+	assert.Equal(t, len(partials), 2)
+	assert.Equal(t, partials[0].Message, "what time")
+	assert.Equal(t, partials[1].Message, "what time")
+
+	// the corrected spelling is recognized by default
+	partials = VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchPartialTranscript","PartialTranscript":"what time"}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Equal(t, len(partials), 2)
+}
+
+// Tests that the last partial transcript on the channel is always Done
+func TestPartialTranscriptFinalDone(t *testing.T) {
+	// made from the final result's transcription
+	partials := VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what","DurationMS":500}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":1000}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}],
+			"Disambiguation":{"NumToShow":1,"ChoiceData":[{"Transcription":"what time is it"}]}}`,
+	)
+	assert.Equal(t, len(partials), 3)
+	assert.Assert(t, !partials[1].Done)
+	assert.Equal(t, partials[2].Message, "what time is it")
+	assert.Equal(t, partials[2].DurationMS, int64(1000))
+	assert.Assert(t, partials[2].Done)
+
+	// not repeated when the server already sent it
+	partials = VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","Done":true}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Equal(t, len(partials), 1)
+	assert.Assert(t, partials[0].Done)
+
+	// a control message without a transcript doesn't replace the last one
+	partials = VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":1000}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","SafeToStopAudio":true}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Equal(t, len(partials), 3)
+	assert.Equal(t, partials[2].Message, "what time")
+	assert.Equal(t, partials[2].Duration, 1000*time.Millisecond)
+	assert.Assert(t, partials[2].Done)
+	assert.Assert(t, partials[2].SafeToStopAudio == nil)
+}
+
+// Tests that no final partial transcript is made up when the search fails, whether
+// before or while the response is read
+func TestPartialTranscriptNoFinalOnError(t *testing.T) {
+	voiceSearchPartials := func(houndifyClient Client, voiceReq VoiceRequest) []PartialTranscript {
+		voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+		partials := make(chan PartialTranscript)
+		received := make(chan []PartialTranscript)
+		go func() {
+			var all []PartialTranscript
+			for partial := range partials {
+				all = append(all, partial)
+			}
+			received <- all
+		}()
+		_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+		assert.Assert(t, err != nil)
+		return <-received
+	}
+
+	// a closed server's address refuses connections
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.URL = server.URL + "/v1/voice"
+	partials := voiceSearchPartials(NewTestHoundifyClient(nil), voiceReq)
+	assert.Equal(t, len(partials), 0)
+
+	// the response is cut short after a partial transcript
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":500}`,
+			testServerResponse,
+		))
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.MaxResponseBytes = 200
+	partials = voiceSearchPartials(houndifyClient, NewTestVoiceRequest())
+	assert.Equal(t, len(partials), 1)
+	assert.Assert(t, !partials[0].Done)
+}
+
+// Tests that the language of a partial transcript is surfaced when the server sends it
+func TestPartialTranscriptLanguage(t *testing.T) {
+	partials := VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"wie spät","Language":"de-DE"}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"wie spät ist es"}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Equal(t, partials[0].Language, "de-DE")
+	assert.Equal(t, partials[1].Language, "")
+}
+
+// Tests that the offset at which the speech ended is reported with SafeToStopAudio
+func TestPartialTranscriptSafeToStopOffset(t *testing.T) {
+	partials := VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":900}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time is it","DurationMS":1400,"SafeToStopAudio":true}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Equal(t, partials[0].SafeToStopOffset, time.Duration(0))
+	assert.Equal(t, partials[1].SafeToStopOffset, 1400*time.Millisecond)
+	assert.Equal(t, partials[2].SafeToStopOffset, time.Duration(0))
+
+	// a control message without a duration of its own uses the audio heard so far
+	partials = VoiceSearchPartialTranscripts(t, NewTestHoundifyClient(nil),
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":900}`,
+		`{"Format":"SoundHoundVoiceSearchParialTranscript","SafeToStopAudio":true}`,
+		`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+	)
+	assert.Assert(t, *partials[1].SafeToStopAudio)
+	assert.Equal(t, partials[1].SafeToStopOffset, 900*time.Millisecond)
+}
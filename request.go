@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"github.com/go-audio/wav"
+	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // A TextRequest holds all the information needed to make a Houndify request.
@@ -21,6 +25,14 @@ type TextRequest struct {
 	RequestID         string
 	RequestInfoFields map[string]interface{}
 	URL               string
+	// If set, overrides the Client's Verbose setting for this request only
+	Verbose *bool
+	// A prebuilt RequestInfo JSON object that the RequestInfoFields are merged over, so
+	// its fields are handled like RequestInfoFields (e.g. the language headers are set
+	// from it). Fields set in RequestInfoFields take precedence over it, and a nil field
+	// removes it from the raw RequestInfo. The fields the SDK sets itself take precedence
+	// over both.
+	RawRequestInfoJSON json.RawMessage
 
 	// Extra header that should be added to http request
 	headers map[string]string
@@ -39,6 +51,17 @@ type VoiceRequest struct {
 	RequestID         string
 	RequestInfoFields map[string]interface{}
 	URL               string
+	// If set, the length of audio the server reports receiving is compared against
+	// ExpectedAudioLength and a warning is logged on a significant mismatch.
+	ExpectedAudioLength time.Duration
+	// If set, the audio is also written to BodyTee as it is sent to the server. This is
+	// useful for debugging exactly what audio was transmitted.
+	BodyTee io.Writer
+	// If set, overrides the Client's Verbose setting for this request only
+	Verbose *bool
+	// If set, closing StopCh aborts the request and VoiceSearch returns ErrStopped. This
+	// is an alternative to cancelling the request's context.
+	StopCh <-chan struct{}
 
 	// Extra header that should be added to http request
 	headers map[string]string
@@ -84,10 +107,10 @@ func BuildRequest(houndReq requestable, c Client) (*http.Request, error) {
 	req.Header.Set("Hound-Request-Authentication", auth.houndRequestAuth)
 	req.Header.Set("Hound-Client-Authentication", auth.houndClientAuth)
 
-	//
-	reqInfo := houndReq.GetRequestInfo()
-	if reqInfo == nil {
-		reqInfo = make(map[string]interface{})
+	// the RequestInfoFields, merged over the raw RequestInfo if the request has one
+	reqInfo, err := requestInfoFields(houndReq)
+	if err != nil {
+		return nil, err
 	}
 
 	reqInfo["TimeStamp"] = auth.timeStamp
@@ -106,12 +129,12 @@ func BuildRequest(houndReq requestable, c Client) (*http.Request, error) {
 		}
 	}
 
-	// Enable conversation state
+	// Enable conversation state. Stateless requests don't send any, without adding it
+	// to the request's fields.
 	if c.enableConversationState {
 		reqInfo["ConversationState"] = c.conversationState
-	} else {
-		var emptyConvState interface{}
-		reqInfo["ConversationState"] = emptyConvState
+	} else if _, ok := reqInfo["ConversationState"]; ok {
+		delete(reqInfo, "ConversationState")
 	}
 
 	requestInfo, err := houndReq.RequestInfo(c, reqInfo)
@@ -124,7 +147,17 @@ func BuildRequest(houndReq requestable, c Client) (*http.Request, error) {
 		return nil, errors.New("failed to create request info: " + err.Error())
 	}
 
-	if !c.RequestInfoInBody {
+	// A RequestInfo too large for a header either goes in the body or is an error
+	inBody := c.RequestInfoInBody
+	if !inBody && c.MaxRequestInfoHeaderBytes > 0 && len(requestInfoJSON) > c.MaxRequestInfoHeaderBytes {
+		if !c.RequestInfoOverflowToBody {
+			return nil, errors.Wrapf(ErrRequestInfoTooLarge, "request info is %d bytes, the limit is %d",
+				len(requestInfoJSON), c.MaxRequestInfoHeaderBytes)
+		}
+		inBody = true
+	}
+
+	if !inBody {
 		req.Header.Set("Hound-Request-Info", string(requestInfoJSON))
 	} else {
 
@@ -143,6 +176,38 @@ func BuildRequest(houndReq requestable, c Client) (*http.Request, error) {
 	return req, nil
 }
 
+// rawRequestInfoer is implemented by requests that can have a raw RequestInfo JSON object
+type rawRequestInfoer interface {
+	rawRequestInfo() json.RawMessage
+}
+
+// requestInfoFields returns the request's RequestInfoFields. If the request has a raw
+// RequestInfo, the fields are merged over it into a new map, where a nil field removes
+// the raw one.
+func requestInfoFields(houndReq requestable) (map[string]interface{}, error) {
+	fields := houndReq.GetRequestInfo()
+	raw, ok := houndReq.(rawRequestInfoer)
+	if !ok || len(raw.rawRequestInfo()) == 0 {
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		return fields, nil
+	}
+
+	merged := make(map[string]interface{})
+	if err := json.Unmarshal(raw.rawRequestInfo(), &merged); err != nil {
+		return nil, errors.New("failed to decode raw request info: " + err.Error())
+	}
+	for key, val := range fields {
+		if val == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = val
+		}
+	}
+	return merged, nil
+}
+
 func (r *TextRequest) NewRequest() (*http.Request, error) {
 	// Use set URL, or fallback to default
 	if len(r.URL) == 0 {
@@ -159,7 +224,7 @@ func (r *TextRequest) NewRequest() (*http.Request, error) {
 }
 
 func (r *TextRequest) AuthInfo(c Client) (authInfo, error) {
-	clientAuth, requestAuth, timestamp, err := generateAuthValues(c.ClientID, c.ClientKey, r.UserID, r.RequestID)
+	clientAuth, requestAuth, timestamp, err := generateAuthValues(c.ClientID, c.ClientKey, c.userID(r.UserID), r.RequestID, c.now())
 	return authInfo{
 		houndClientAuth:  clientAuth,
 		houndRequestAuth: requestAuth,
@@ -171,14 +236,35 @@ func (r *TextRequest) RequestInfo(c Client, reqInfo requestInfo) (requestInfo, e
 	if r.RequestInfoFields == nil {
 		r.RequestInfoFields = reqInfo
 	}
-	timestamp := r.RequestInfoFields["TimeStamp"].(int64)
-	return createRequestInfo(c.ClientID, r.RequestID, timestamp, r.RequestInfoFields)
+	// reqInfo has the RequestInfoFields merged over the RawRequestInfoJSON
+	timestamp := reqInfo["TimeStamp"].(int64)
+	return createRequestInfo(c.ClientID, c.userID(r.UserID), r.RequestID, timestamp, reqInfo)
 }
 
 func (r *TextRequest) GetRequestInfo() map[string]interface{} {
 	return r.RequestInfoFields
 }
 
+func (r *TextRequest) rawRequestInfo() json.RawMessage {
+	return r.RawRequestInfoJSON
+}
+
+// SetLanguage sets the input language of the query in the RequestInfoFields
+func (r *TextRequest) SetLanguage(lang Language) {
+	if r.RequestInfoFields == nil {
+		r.RequestInfoFields = make(map[string]interface{})
+	}
+	setLanguage(r.RequestInfoFields, lang)
+}
+
+// SetLocation sets the location of the user in the RequestInfoFields
+func (r *TextRequest) SetLocation(loc Location) {
+	if r.RequestInfoFields == nil {
+		r.RequestInfoFields = make(map[string]interface{})
+	}
+	setLocation(r.RequestInfoFields, loc)
+}
+
 func (r *TextRequest) WithContext(ctx context.Context) {
 	r.ctx = ctx
 }
@@ -202,7 +288,7 @@ func (r *VoiceRequest) NewRequest() (*http.Request, error) {
 }
 
 func (r *VoiceRequest) AuthInfo(c Client) (authInfo, error) {
-	clientAuth, requestAuth, timestamp, err := generateAuthValues(c.ClientID, c.ClientKey, r.UserID, r.RequestID)
+	clientAuth, requestAuth, timestamp, err := generateAuthValues(c.ClientID, c.ClientKey, c.userID(r.UserID), r.RequestID, c.now())
 	return authInfo{
 		houndClientAuth:  clientAuth,
 		houndRequestAuth: requestAuth,
@@ -215,13 +301,120 @@ func (r *VoiceRequest) RequestInfo(c Client, reqInfo requestInfo) (requestInfo,
 		r.RequestInfoFields = reqInfo
 	}
 	timestamp := r.RequestInfoFields["TimeStamp"].(int64)
-	return createRequestInfo(c.ClientID, r.RequestID, timestamp, r.RequestInfoFields)
+	return createRequestInfo(c.ClientID, c.userID(r.UserID), r.RequestID, timestamp, r.RequestInfoFields)
 }
 
 func (r *VoiceRequest) GetRequestInfo() map[string]interface{} {
 	return r.RequestInfoFields
 }
 
+// SetLanguage sets the input language of the audio in the RequestInfoFields
+func (r *VoiceRequest) SetLanguage(lang Language) {
+	if r.RequestInfoFields == nil {
+		r.RequestInfoFields = make(map[string]interface{})
+	}
+	setLanguage(r.RequestInfoFields, lang)
+}
+
+// stopped reports whether the request's StopCh has been closed
+func (r *VoiceRequest) stopped() bool {
+	select {
+	case <-r.StopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateAudioConsistency checks the sample rate in Hz and the encoding (e.g. "PCM")
+// declared by the "SampleRate" and "AudioFormat" RequestInfoFields against the header
+// of the AudioStream, as mismatched audio gives bad results. An error is returned if
+// they don't match.
+//
+// The header can only be read from an AudioStream that is an io.ReadSeeker, such as a
+// file, and the stream is left at the position it started at. For any other stream
+// with a declared sample rate or format, an error is returned as it can't be checked.
+// An AudioStream that isn't a WAV has no header to check against, and is not an error.
+func (r VoiceRequest) ValidateAudioConsistency() error {
+	sampleRate, err := requestInfoInt(r.RequestInfoFields, "SampleRate")
+	if err != nil {
+		return err
+	}
+	audioFormat, _ := r.RequestInfoFields["AudioFormat"].(string)
+	if sampleRate == 0 && audioFormat == "" {
+		return nil
+	}
+	stream, ok := r.AudioStream.(io.ReadSeeker)
+	if !ok {
+		return errors.New("can't check the audio format of an AudioStream that isn't an io.ReadSeeker")
+	}
+	start, err := stream.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "failed to read audio stream")
+	}
+	d := wav.NewDecoder(stream)
+	d.ReadInfo()
+	if _, err := stream.Seek(start, io.SeekStart); err != nil {
+		return errors.Wrap(err, "failed to rewind audio stream")
+	}
+	if !d.IsValidFile() {
+		// not a WAV, so there is no header to check against
+		return nil
+	}
+
+	if sampleRate != 0 && int(d.SampleRate) != sampleRate {
+		return errors.Errorf("declared sample rate is %dHz, but the WAV header says %dHz", sampleRate, d.SampleRate)
+	}
+	if audioFormat != "" {
+		format, ok := wavAudioFormats[d.WavAudioFormat]
+		if !ok {
+			format = fmt.Sprintf("format %d", d.WavAudioFormat)
+		}
+		if !strings.EqualFold(format, audioFormat) {
+			return errors.Errorf("declared audio format is %q, but the WAV header says %q", audioFormat, format)
+		}
+	}
+	return nil
+}
+
+// requestInfoInt returns the whole number RequestInfo field key, which is 0 if it isn't
+// set
+func requestInfoInt(fields map[string]interface{}, key string) (int, error) {
+	switch v := fields[key].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), nil
+		}
+	}
+	return 0, errors.Errorf("request info field %s is %v, not a whole number", key, fields[key])
+}
+
+// The names of the audio formats in WAV headers
+var wavAudioFormats = map[uint16]string{
+	1: "PCM",
+	3: "IEEE_FLOAT",
+	6: "ALAW",
+	7: "MULAW",
+}
+
+// SetLocation sets the location of the user in the RequestInfoFields
+func (r *VoiceRequest) SetLocation(loc Location) {
+	if r.RequestInfoFields == nil {
+		r.RequestInfoFields = make(map[string]interface{})
+	}
+	setLocation(r.RequestInfoFields, loc)
+}
+
 func (r *VoiceRequest) WithContext(ctx context.Context) {
 	r.ctx = ctx
 }
@@ -2,7 +2,7 @@ package houndify
 
 type requestInfo map[string]interface{}
 
-func createRequestInfo(clientID, requestID string, timeStamp int64, extraFields map[string]interface{}) (requestInfo, error) {
+func createRequestInfo(clientID, userID, requestID string, timeStamp int64, extraFields map[string]interface{}) (requestInfo, error) {
 	reqInfo := make(requestInfo)
 
 	if len(extraFields) > 0 {
@@ -12,8 +12,16 @@ func createRequestInfo(clientID, requestID string, timeStamp int64, extraFields
 			}
 		}
 	}
+	// The server needs to know when a location was measured, default to now. This is
+	// only set on the copy, so a reused request doesn't keep a stale time.
+	if _, ok := reqInfo["Latitude"]; ok {
+		if _, ok := reqInfo["PositionTime"]; !ok {
+			reqInfo["PositionTime"] = timeStamp
+		}
+	}
 	reqInfo["TimeStamp"] = timeStamp
 	reqInfo["ClientID"] = clientID
+	reqInfo["UserID"] = userID
 	reqInfo["RequestID"] = requestID
 	reqInfo["SDK"] = "Go"
 	reqInfo["SDKVersion"] = "0.1"
@@ -2,11 +2,17 @@ package houndify_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
 	. "github.com/soundhound/houndify-sdk-go"
 	"gotest.tools/assert"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type RoundTripFunc func(req *http.Request) *http.Response
@@ -127,3 +133,158 @@ func TestBuildTextRequest(t *testing.T) {
 	assert.NilError(t, err)
 	mockClient.Do(req)
 }
+
+// Tests that the fields of TextRequest.RawRequestInfoJSON are merged into the RequestInfo
+func TestRawRequestInfoJSON(t *testing.T) {
+	textReq := NewTestTextRequest()
+	textReq.RequestInfoFields["City"] = "Toronto"
+	textReq.RawRequestInfoJSON = json.RawMessage(`{
+		"City": "Santa Clara",
+		"Latitude": 37.35,
+		"ClientMatches": [{"Expression": "\"hello\"", "Result": {"Intent": "HELLO"}}],
+		"ClientID": "NotTheClientID"
+	}`)
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	req, err := BuildRequest(&textReq, houndifyClient)
+	assert.NilError(t, err)
+
+	reqInfo := make(map[string]interface{})
+	err = json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo)
+	assert.NilError(t, err)
+	assert.Equal(t, reqInfo["Latitude"], 37.35)
+	assert.Equal(t, len(reqInfo["ClientMatches"].([]interface{})), 1)
+	assert.Equal(t, reqInfo["City"], "Toronto")
+	assert.Equal(t, reqInfo["ClientID"], houndifyClient.ClientID)
+}
+
+// Tests that the fields of TextRequest.RawRequestInfoJSON are handled like the
+// RequestInfoFields, and that a nil field removes a raw one
+func TestRawRequestInfoJSONPrecedence(t *testing.T) {
+	textReq := NewTestTextRequest()
+	textReq.RequestInfoFields["ClientMatches"] = nil
+	textReq.RawRequestInfoJSON = json.RawMessage(`{
+		"InputLanguageEnglishName": "German",
+		"InputLanguageIETFTag": "de-DE",
+		"Latitude": 52.52,
+		"ClientMatches": [{"Expression": "\"hallo\"", "Result": {"Intent": "HELLO"}}]
+	}`)
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.Clock = func() time.Time { return time.Unix(1565000000, 0) }
+	req, err := BuildRequest(&textReq, houndifyClient)
+	assert.NilError(t, err)
+	assert.Equal(t, req.Header.Get("Hound-Input-Language-IETF-Tag"), "de-DE")
+
+	reqInfo := make(map[string]interface{})
+	err = json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo)
+	assert.NilError(t, err)
+	assert.Equal(t, reqInfo["PositionTime"], float64(1565000000))
+	_, ok := reqInfo["ClientMatches"]
+	assert.Assert(t, !ok)
+}
+
+// Tests that invalid RawRequestInfoJSON fails the request instead of panicking
+func TestInvalidRawRequestInfoJSON(t *testing.T) {
+	textReq := NewTestTextRequest()
+	textReq.RawRequestInfoJSON = json.RawMessage(`{"City": `)
+	textReq.WithContext(context.Background())
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	_, err := houndifyClient.TextSearch(textReq)
+	assert.ErrorContains(t, err, "failed to decode raw request info")
+}
+
+// Tests that with RequestInfoInBody, a voice request's body is the RequestInfo followed
+// by the audio, with the RequestInfo's length in the Hound-Request-Info-Length header
+func TestVoiceRequestInfoInBody(t *testing.T) {
+	audio := []byte("RIFF fake audio data")
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.Header.Get("Hound-Request-Info"), "")
+		length, err := strconv.Atoi(req.Header.Get("Hound-Request-Info-Length"))
+		assert.NilError(t, err)
+
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, len(body), length+len(audio))
+
+		reqInfo := make(map[string]interface{})
+		assert.NilError(t, json.Unmarshal(body[:length], &reqInfo))
+		assert.Equal(t, reqInfo["RequestID"], "TestRequestID")
+		assert.DeepEqual(t, body[length:], audio)
+
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`,
+		))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	houndifyClient.RequestInfoInBody = true
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(audio)
+
+	partials := make(chan PartialTranscript)
+	DiscardPartialTranscripts(partials)
+	_, err := houndifyClient.VoiceSearch(voiceReq, partials)
+	assert.NilError(t, err)
+	assert.Equal(t, houndifyClient.RequestInfoInBody, true)
+}
+
+// Tests that a RequestInfo larger than Client.MaxRequestInfoHeaderBytes is an error, or
+// is sent in the body with RequestInfoOverflowToBody
+func TestMaxRequestInfoHeaderBytes(t *testing.T) {
+	textReq := NewTestTextRequest()
+	textReq.RequestInfoFields["ClientMatches"] = strings.Repeat("x", 4096)
+
+	houndifyClient := NewTestHoundifyClient(nil)
+	houndifyClient.MaxRequestInfoHeaderBytes = 1024
+	_, err := BuildRequest(&textReq, houndifyClient)
+	assert.Equal(t, errors.Cause(err), ErrRequestInfoTooLarge)
+
+	houndifyClient.RequestInfoOverflowToBody = true
+	req, err := BuildRequest(&textReq, houndifyClient)
+	assert.NilError(t, err)
+	assert.Equal(t, req.Header.Get("Hound-Request-Info"), "")
+	body, err := ioutil.ReadAll(req.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, req.Header.Get("Hound-Request-Info-Length"), strconv.Itoa(len(body)))
+	assert.Assert(t, strings.Contains(string(body), strings.Repeat("x", 4096)))
+
+	// a small RequestInfo still goes in the header
+	smallReq := NewTestTextRequest()
+	req, err = BuildRequest(&smallReq, houndifyClient)
+	assert.NilError(t, err)
+	assert.Assert(t, req.Header.Get("Hound-Request-Info") != "")
+}
+
+// Tests that a declared sample rate or format that doesn't match the WAV header is an error
+func TestValidateAudioConsistency(t *testing.T) {
+	audio := bytes.NewReader(NewTestWAV(8000, 1600))
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = audio
+	voiceReq.RequestInfoFields["SampleRate"] = 16000
+	assert.ErrorContains(t, voiceReq.ValidateAudioConsistency(), "declared sample rate is 16000Hz, but the WAV header says 8000Hz")
+	// the audio can still be sent from the start
+	assert.Equal(t, audio.Len(), 44+1600)
+
+	voiceReq.RequestInfoFields["SampleRate"] = 8000
+	assert.NilError(t, voiceReq.ValidateAudioConsistency())
+	// as decoded from JSON
+	voiceReq.RequestInfoFields["SampleRate"] = float64(8000)
+	assert.NilError(t, voiceReq.ValidateAudioConsistency())
+
+	voiceReq.RequestInfoFields["AudioFormat"] = "MULAW"
+	assert.ErrorContains(t, voiceReq.ValidateAudioConsistency(), `declared audio format is "MULAW", but the WAV header says "PCM"`)
+	voiceReq.RequestInfoFields["AudioFormat"] = "pcm"
+	assert.NilError(t, voiceReq.ValidateAudioConsistency())
+
+	// raw audio has no header to check against
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 1600))
+	voiceReq.RequestInfoFields["SampleRate"] = 16000
+	assert.NilError(t, voiceReq.ValidateAudioConsistency())
+
+	// a stream that can't be rewound can't be checked
+	voiceReq.AudioStream = ioutil.NopCloser(strings.NewReader("RIFF"))
+	assert.ErrorContains(t, voiceReq.ValidateAudioConsistency(), "isn't an io.ReadSeeker")
+}
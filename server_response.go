@@ -4,9 +4,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// The fraction of the expected audio length the received audio length may differ by
+// before it is considered a mismatch
+const audioLengthTolerance = 0.1
+
+type (
+	// A HoundifyResponse is the final response from the Hound server, with the fields
+	// most commonly used by SDK users. See the Houndify docs for all the fields.
+	HoundifyResponse struct {
+		Format         string                   `json:"Format"`
+		FormatVersion  string                   `json:"FormatVersion"`
+		Status         string                   `json:"Status"`
+		ErrorMessage   string                   `json:"ErrorMessage"`
+		NumToReturn    int                      `json:"NumToReturn"`
+		AllResults     []HoundifyResponseResult `json:"AllResults"`
+		Disambiguation *HoundifyDisambiguation  `json:"Disambiguation"`
+	}
+
+	// A HoundifyResponseResult is one of the results in a HoundifyResponse
+	HoundifyResponseResult struct {
+		CommandKind         string      `json:"CommandKind"`
+		SpokenResponse      string      `json:"SpokenResponse"`
+		SpokenResponseLong  string      `json:"SpokenResponseLong"`
+		WrittenResponse     string      `json:"WrittenResponse"`
+		WrittenResponseLong string      `json:"WrittenResponseLong"`
+		ConversationState   interface{} `json:"ConversationState"`
+		// How confident the server is that it understood the query, from 0 to 1. It is
+		// nil if the server didn't include it.
+		UnderstandingConfidence *float64 `json:"UnderstandingConfidence"`
+	}
+
+	// A HoundifyDisambiguation holds the alternative transcriptions the server
+	// considered for a voice query
+	HoundifyDisambiguation struct {
+		NumToShow  int                            `json:"NumToShow"`
+		ChoiceData []HoundifyDisambiguationChoice `json:"ChoiceData"`
+	}
+
+	// A HoundifyDisambiguationChoice is one of the transcriptions in a
+	// HoundifyDisambiguation
+	HoundifyDisambiguationChoice struct {
+		Transcription      string  `json:"Transcription"`
+		ConfidenceScore    float64 `json:"ConfidenceScore"`
+		FixedTranscription string  `json:"FixedTranscription"`
+	}
+)
+
+// A SearchResponse is the final response from the Hound server as both the raw JSON,
+// e.g. for logging, and parsed into a HoundifyResponse
+type SearchResponse struct {
+	Raw    string
+	Parsed *HoundifyResponse
+}
+
+// newSearchResponse parses the body returned by a search. If the search failed, or the
+// body can't be parsed, the raw body is still returned along with the error.
+func newSearchResponse(body string, searchErr error) (*SearchResponse, error) {
+	response := &SearchResponse{Raw: body}
+	if searchErr != nil {
+		return response, searchErr
+	}
+	parsed, err := ParseResponse(body)
+	if err != nil {
+		return response, err
+	}
+	response.Parsed = parsed
+	return response, nil
+}
+
+// ParseResponse will take final server response JSON (as a string) and parse it into a
+// HoundifyResponse. An error is returned if the string is invalid JSON.
+func ParseResponse(serverResponseJSON string) (*HoundifyResponse, error) {
+	response := &HoundifyResponse{}
+	if err := json.Unmarshal([]byte(serverResponseJSON), response); err != nil {
+		return nil, errors.Wrap(err, "failed to decode json")
+	}
+	return response, nil
+}
+
 // ParseWrittenResponse will take final server response JSON (as a string)
 // and parse out the human readable text to be displayed or spoken the end user.
 // If the string is invalid JSON, the server had an error, or there was nothing
@@ -27,6 +107,99 @@ func ParseWrittenResponse(serverResponseJSON string) (string, error) {
 	return result["AllResults"].([]interface{})[0].(map[string]interface{})["WrittenResponseLong"].(string), nil
 }
 
+// ParseWrittenResponseN is like ParseWrittenResponse, but returns the written response
+// of the result at the given index of AllResults, for when the server returns more than
+// one result. An error is returned if there is no result at the index.
+func ParseWrittenResponseN(serverResponseJSON string, index int) (string, error) {
+	result, err := parseResult(serverResponseJSON, index)
+	if err != nil {
+		return "", err
+	}
+	return result.WrittenResponseLong, nil
+}
+
+// ParseWrittenResponseLength is like ParseWrittenResponse, but returns the written
+// response matching the length preference the request was made with. shortOrLong is
+// the value of the "ResponseAudioShortOrLong" RequestInfo field: if it is "Short" the
+// WrittenResponse is returned, otherwise the WrittenResponseLong is.
+func ParseWrittenResponseLength(serverResponseJSON string, shortOrLong string) (string, error) {
+	result, err := parseResult(serverResponseJSON, 0)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(shortOrLong, "Short") && result.WrittenResponse != "" {
+		return result.WrittenResponse, nil
+	}
+	return result.WrittenResponseLong, nil
+}
+
+// ParseResultConversationStates will take final server response JSON (as a string) and
+// parse out the ConversationState of each of the results, in the order of AllResults.
+// This is useful when the user picks one of several results, as the SDK only stores the
+// first result's state.
+func ParseResultConversationStates(serverResponseJSON string) ([]interface{}, error) {
+	results, err := parseResults(serverResponseJSON)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]interface{}, len(results))
+	for i, result := range results {
+		states[i] = result.ConversationState
+	}
+	return states, nil
+}
+
+// ParseResultsAboveConfidence will take final server response JSON (as a string) and
+// return only the results whose UnderstandingConfidence is at least min, in order.
+// includeMissing decides whether results without an UnderstandingConfidence are
+// included.
+func ParseResultsAboveConfidence(serverResponseJSON string, min float64, includeMissing bool) ([]HoundifyResponseResult, error) {
+	results, err := parseResults(serverResponseJSON)
+	if err != nil {
+		return nil, err
+	}
+	confident := []HoundifyResponseResult{}
+	for _, result := range results {
+		if result.UnderstandingConfidence == nil {
+			if includeMissing {
+				confident = append(confident, result)
+			}
+			continue
+		}
+		if *result.UnderstandingConfidence >= min {
+			confident = append(confident, result)
+		}
+	}
+	return confident, nil
+}
+
+// parseResult parses the result at the given index of a successful server response
+func parseResult(serverResponseJSON string, index int) (*HoundifyResponseResult, error) {
+	results, err := parseResults(serverResponseJSON)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(results) {
+		return nil, errors.Errorf("no result at index %d, there are %d results", index, len(results))
+	}
+	return &results[index], nil
+}
+
+// parseResults parses all the results of a successful server response
+func parseResults(serverResponseJSON string) ([]HoundifyResponseResult, error) {
+	response, err := ParseResponse(serverResponseJSON)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(response.Status, "OK") {
+		return nil, errors.New(response.ErrorMessage)
+	}
+	if response.NumToReturn < 1 || len(response.AllResults) < 1 {
+		return nil, errors.New("no results to return")
+	}
+	return response.AllResults, nil
+}
+
 func parseConversationState(serverResponseJSON string) (interface{}, error) {
 	result := make(map[string]interface{})
 	err := json.Unmarshal([]byte(serverResponseJSON), &result)
@@ -46,3 +219,56 @@ func parseConversationState(serverResponseJSON string) (interface{}, error) {
 	}
 	return result["AllResults"].([]interface{})[0].(map[string]interface{})["ConversationState"], nil
 }
+
+// ParseAudioLength will take final server response JSON (as a string) and parse out
+// the length of audio the server received for a voice request.
+func ParseAudioLength(serverResponseJSON string) (time.Duration, error) {
+	result := struct {
+		AudioLength *float64 `json:"AudioLength"`
+	}{}
+	err := json.Unmarshal([]byte(serverResponseJSON), &result)
+	if err != nil {
+		return 0, errors.New("failed to decode json")
+	}
+	if result.AudioLength == nil {
+		return 0, errors.New("no audio length in response")
+	}
+	return time.Duration(*result.AudioLength * float64(time.Second)), nil
+}
+
+// ParseServerGeneratedId will take server response JSON (as a string) and parse out the
+// ServerGeneratedId, which identifies the request when reporting problems to support.
+// It works for error responses too, and the bool is false if the response isn't JSON
+// or has no ServerGeneratedId.
+func ParseServerGeneratedId(serverResponseJSON string) (string, bool) {
+	result := struct {
+		ServerGeneratedId string `json:"ServerGeneratedId"`
+	}{}
+	if err := json.Unmarshal([]byte(serverResponseJSON), &result); err != nil {
+		return "", false
+	}
+	return result.ServerGeneratedId, result.ServerGeneratedId != ""
+}
+
+// compareFormatVersions compares two dotted FormatVersions numerically, returning -1,
+// 0 or 1 if a is older than, the same as, or newer than b. Missing or non numeric
+// parts count as 0.
+func compareFormatVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum < bNum {
+			return -1
+		}
+		if aNum > bNum {
+			return 1
+		}
+	}
+	return 0
+}
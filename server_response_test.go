@@ -0,0 +1,124 @@
+package houndify_test
+
+import (
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"testing"
+)
+
+// Tests that the written response matching the requested length is returned
+func TestParseWrittenResponseLength(t *testing.T) {
+	short, err := ParseWrittenResponseLength(testServerResponse, "Short")
+	assert.NilError(t, err)
+	assert.Equal(t, short, "It is noon.")
+
+	long, err := ParseWrittenResponseLength(testServerResponse, "Long")
+	assert.NilError(t, err)
+	assert.Equal(t, long, "It is twelve o'clock noon.")
+}
+
+// Tests getting the written response of a result other than the first
+func TestParseWrittenResponseN(t *testing.T) {
+	serverResponse := `{
+		"Status": "OK",
+		"NumToReturn": 2,
+		"AllResults": [
+			{"WrittenResponseLong": "Playing Yesterday by The Beatles."},
+			{"WrittenResponseLong": "Playing Yesterday by Leona Lewis."}
+		]
+	}`
+
+	writtenResponse, err := ParseWrittenResponseN(serverResponse, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, writtenResponse, "Playing Yesterday by Leona Lewis.")
+
+	_, err = ParseWrittenResponseN(serverResponse, 2)
+	assert.ErrorContains(t, err, "no result at index 2")
+}
+
+// Tests that the conversation state of every result is returned
+func TestParseResultConversationStates(t *testing.T) {
+	serverResponse := `{
+		"Status": "OK",
+		"NumToReturn": 2,
+		"AllResults": [
+			{"ConversationState": {"Song": "Yesterday", "Artist": "The Beatles"}},
+			{"ConversationState": {"Song": "Yesterday", "Artist": "Leona Lewis"}}
+		]
+	}`
+
+	states, err := ParseResultConversationStates(serverResponse)
+	assert.NilError(t, err)
+	assert.Equal(t, len(states), 2)
+	assert.DeepEqual(t, states[0], map[string]interface{}{"Song": "Yesterday", "Artist": "The Beatles"})
+	assert.DeepEqual(t, states[1], map[string]interface{}{"Song": "Yesterday", "Artist": "Leona Lewis"})
+}
+
+// Tests that TextSearchParsed returns both the raw and the parsed response
+func TestTextSearchParsed(t *testing.T) {
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, testServerResponse)
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	response, err := houndifyClient.TextSearchParsed(NewTestTextRequest())
+	assert.NilError(t, err)
+	assert.Equal(t, response.Raw, testServerResponse)
+
+	parsed, err := ParseResponse(response.Raw)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, response.Parsed, parsed)
+	assert.Equal(t, response.Parsed.AllResults[0].WrittenResponse, "It is noon.")
+}
+
+// Tests that only the results understood with enough confidence are returned
+func TestParseResultsAboveConfidence(t *testing.T) {
+	serverResponse := `{
+		"Status": "OK",
+		"NumToReturn": 4,
+		"AllResults": [
+			{"WrittenResponseLong": "high", "UnderstandingConfidence": 0.9},
+			{"WrittenResponseLong": "low", "UnderstandingConfidence": 0.2},
+			{"WrittenResponseLong": "missing"},
+			{"WrittenResponseLong": "threshold", "UnderstandingConfidence": 0.5}
+		]
+	}`
+	writtenResponses := func(results []HoundifyResponseResult) []string {
+		responses := []string{}
+		for _, result := range results {
+			responses = append(responses, result.WrittenResponseLong)
+		}
+		return responses
+	}
+
+	results, err := ParseResultsAboveConfidence(serverResponse, 0.5, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, writtenResponses(results), []string{"high", "threshold"})
+
+	results, err = ParseResultsAboveConfidence(serverResponse, 0.5, true)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, writtenResponses(results), []string{"high", "missing", "threshold"})
+
+	results, err = ParseResultsAboveConfidence(serverResponse, 0.95, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+}
+
+// Tests that the ServerGeneratedId is parsed out of error responses too
+func TestParseServerGeneratedId(t *testing.T) {
+	id, ok := ParseServerGeneratedId(`{
+		"Format": "SoundHoundVoiceSearchResult",
+		"Status": "Error",
+		"ErrorMessage": "Invalid audio",
+		"ServerGeneratedId": "b2a1b2f0-5f5b-4b3c-9a1e-1f2e3d4c5b6a"
+	}`)
+	assert.Assert(t, ok)
+	assert.Equal(t, id, "b2a1b2f0-5f5b-4b3c-9a1e-1f2e3d4c5b6a")
+
+	_, ok = ParseServerGeneratedId(`{"Status": "Error", "ErrorMessage": "Invalid audio"}`)
+	assert.Assert(t, !ok)
+
+	_, ok = ParseServerGeneratedId(`Service Unavailable`)
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,96 @@
+package houndify
+
+import (
+	"github.com/go-audio/wav"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// StreamFileWithProgress streams the WAV file at path to the server as a voice request,
+// paced in real time as if the audio was being recorded, and returns the final server
+// response. This is convenient for testing with batches of recorded queries.
+//
+// If set, onPartial is called with each partial transcript, and onProgress is called
+// with the total number of bytes of the file sent so far. Streaming stops early once the
+// server reports it is safe to stop sending audio. Both callbacks are done by the time
+// StreamFileWithProgress returns.
+func StreamFileWithProgress(client *Client, path string, onPartial func(PartialTranscript), onProgress func(int64)) (string, error) {
+	return StreamFileRequestWithProgress(client, VoiceRequest{}, path, onPartial, onProgress)
+}
+
+// StreamFileRequestWithProgress is like StreamFileWithProgress, but sends req with the
+// file as its AudioStream, so the UserID, RequestInfoFields etc. can be set. A RequestID
+// is generated if req doesn't have one.
+func StreamFileRequestWithProgress(client *Client, req VoiceRequest, path string, onPartial func(PartialTranscript), onProgress func(int64)) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open audio file")
+	}
+	defer f.Close()
+
+	// the WAV header determines how fast to send the audio
+	d := wav.NewDecoder(f)
+	if d.ReadInfo(); !d.IsValidFile() {
+		return "", errors.Errorf("%s is not a valid WAV file", path)
+	}
+	// the header is sent too, so start again from the beginning of the file
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", errors.Wrap(err, "failed to read audio file")
+	}
+
+	safeToStop := make(chan struct{})
+	req.AudioStream = &progressReader{
+		r:          newPacedReader(f, int(d.AvgBytesPerSec)),
+		stop:       safeToStop,
+		onProgress: onProgress,
+	}
+	if req.RequestID == "" {
+		req.RequestID = newRequestID()
+	}
+
+	partials := make(chan PartialTranscript)
+	var stopOnce sync.Once
+	partialsDone := make(chan struct{})
+	go func() {
+		defer close(partialsDone)
+		for partial := range partials {
+			if partial.SafeToStopAudio != nil && *partial.SafeToStopAudio {
+				stopOnce.Do(func() { close(safeToStop) })
+			}
+			if onPartial != nil {
+				onPartial(partial)
+			}
+		}
+	}()
+
+	serverResponse, err := client.VoiceSearch(req, partials)
+	<-partialsDone
+	return serverResponse, err
+}
+
+// progressReader reports the number of bytes read from r so far, and ends early once
+// stop is closed
+type progressReader struct {
+	r          io.Reader
+	stop       <-chan struct{}
+	onProgress func(int64)
+	read       int64
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	select {
+	case <-p.stop:
+		return 0, io.EOF
+	default:
+	}
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.read += int64(n)
+		if p.onProgress != nil {
+			p.onProgress(p.read)
+		}
+	}
+	return n, err
+}
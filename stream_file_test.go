@@ -0,0 +1,96 @@
+package houndify_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns a WAV file of silent 16-bit, mono audio with the given sample rate and number
+// of bytes of samples
+func NewTestWAV(sampleRate, dataBytes int) []byte {
+	var wav bytes.Buffer
+	wav.WriteString("RIFF")
+	binary.Write(&wav, binary.LittleEndian, uint32(36+dataBytes))
+	wav.WriteString("WAVEfmt ")
+	binary.Write(&wav, binary.LittleEndian, uint32(16)) // fmt chunk size
+	binary.Write(&wav, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&wav, binary.LittleEndian, uint16(1))  // channels
+	binary.Write(&wav, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&wav, binary.LittleEndian, uint32(sampleRate*2)) // bytes per second
+	binary.Write(&wav, binary.LittleEndian, uint16(2))            // block align
+	binary.Write(&wav, binary.LittleEndian, uint16(16))           // bits per sample
+	wav.WriteString("data")
+	binary.Write(&wav, binary.LittleEndian, uint32(dataBytes))
+	wav.Write(make([]byte, dataBytes))
+	return wav.Bytes()
+}
+
+// Tests that StreamFileWithProgress reports progress and partials, and returns the
+// final result
+func TestStreamFileWithProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "houndify")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "query.wav")
+	assert.NilError(t, ioutil.WriteFile(path, NewTestWAV(8000, 3200), 0644))
+
+	var sent []byte
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		sent, _ = ioutil.ReadAll(req.Body)
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":100}`,
+			testServerResponse,
+		))
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	var messages []string
+	var progress []int64
+	serverResponse, err := StreamFileWithProgress(&houndifyClient, path,
+		func(partial PartialTranscript) {
+			messages = append(messages, partial.Message)
+		},
+		func(bytesSent int64) {
+			progress = append(progress, bytesSent)
+		},
+	)
+	assert.NilError(t, err)
+	assert.Equal(t, serverResponse, testServerResponse)
+	assert.DeepEqual(t, messages, []string{"what time", "what time"})
+
+	// the whole file is sent, a tenth of a second at a time
+	assert.Equal(t, len(sent), 44+3200)
+	assert.Assert(t, len(progress) > 1, "got %v", progress)
+	assert.Equal(t, progress[len(progress)-1], int64(44+3200))
+}
+
+// Tests that StreamFileRequestWithProgress sends the given request's fields
+func TestStreamFileRequestWithProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "houndify")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "query.wav")
+	assert.NilError(t, ioutil.WriteFile(path, NewTestWAV(8000, 800), 0644))
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		reqInfo := make(map[string]interface{})
+		assert.NilError(t, json.Unmarshal([]byte(req.Header.Get("Hound-Request-Info")), &reqInfo))
+		assert.Equal(t, reqInfo["UserID"], "TestUserID")
+		assert.Equal(t, reqInfo["RequestID"], "TestRequestID")
+		ioutil.ReadAll(req.Body)
+		return NewTestResponse(200, NewTestVoiceResponseBody(testServerResponse))
+	})
+	houndifyClient := NewTestHoundifyClient(mockClient)
+
+	serverResponse, err := StreamFileRequestWithProgress(&houndifyClient, NewTestVoiceRequest(), path, nil, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, serverResponse, testServerResponse)
+}
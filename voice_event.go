@@ -0,0 +1,76 @@
+package houndify
+
+import (
+	"errors"
+)
+
+// A VoiceEventKind identifies what a VoiceEvent holds
+type VoiceEventKind int
+
+const (
+	// A partial transcript, held in the VoiceEvent's Partial
+	VoicePartialEvent VoiceEventKind = iota
+	// The server has enough audio, so it's safe to stop streaming it. The partial
+	// transcript carrying the flag is held in the VoiceEvent's Partial.
+	VoiceSafeToStopEvent
+	// The final server response JSON, held in the VoiceEvent's Result
+	VoiceResultEvent
+	// The request failed, the error is held in the VoiceEvent's Err. If the server
+	// responded, its response is held in the VoiceEvent's Result.
+	VoiceErrorEvent
+)
+
+// A VoiceEvent is one of the events of a voice search, see VoiceSearchEvents.
+type VoiceEvent struct {
+	Kind    VoiceEventKind
+	Partial PartialTranscript
+	Result  string
+	Err     error
+}
+
+// VoiceSearchEvents sends an audio request like VoiceSearch, but delivers everything
+// that happens on a single channel: partial transcripts, the safe to stop audio
+// signal, and finally either the final server response or an error. The channel is
+// closed after the result or error event, and must be drained by the caller.
+//
+// An error is returned without starting the request if the VoiceRequest has no
+// AudioStream.
+func (c *Client) VoiceSearchEvents(voiceReq VoiceRequest) (<-chan VoiceEvent, error) {
+	if voiceReq.AudioStream == nil {
+		return nil, errors.New("voice request has no audio stream")
+	}
+
+	events := make(chan VoiceEvent)
+	partialTranscripts := make(chan PartialTranscript)
+	type voiceResult struct {
+		serverResponse string
+		err            error
+	}
+	result := make(chan voiceResult, 1)
+
+	go func() {
+		serverResponse, err := c.VoiceSearch(voiceReq, partialTranscripts)
+		result <- voiceResult{serverResponse, err}
+	}()
+
+	go func() {
+		defer close(events)
+		// the partial transcript channel is closed once they've all been sent, after
+		// the search finished
+		for partial := range partialTranscripts {
+			kind := VoicePartialEvent
+			if partial.SafeToStopAudio != nil && *partial.SafeToStopAudio {
+				kind = VoiceSafeToStopEvent
+			}
+			events <- VoiceEvent{Kind: kind, Partial: partial}
+		}
+		res := <-result
+		if res.err != nil {
+			events <- VoiceEvent{Kind: VoiceErrorEvent, Result: res.serverResponse, Err: res.err}
+			return
+		}
+		events <- VoiceEvent{Kind: VoiceResultEvent, Result: res.serverResponse}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,47 @@
+package houndify_test
+
+import (
+	"bytes"
+	. "github.com/soundhound/houndify-sdk-go"
+	"gotest.tools/assert"
+	"net/http"
+	"testing"
+)
+
+// Tests the sequence of events delivered by VoiceSearchEvents for a full interaction
+func TestVoiceSearchEvents(t *testing.T) {
+	result := `{"Format":"SoundHoundVoiceSearchResult","Status":"OK","NumToReturn":1,"AllResults":[{}]}`
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		return NewTestResponse(200, NewTestVoiceResponseBody(
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what","DurationMS":500}`,
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time","DurationMS":1000}`,
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time is it","DurationMS":1500,"SafeToStopAudio":true}`,
+			`{"Format":"SoundHoundVoiceSearchParialTranscript","PartialTranscript":"what time is it","DurationMS":1800,"Done":true}`,
+			result,
+		))
+	})
+
+	houndifyClient := NewTestHoundifyClient(mockClient)
+	voiceReq := NewTestVoiceRequest()
+	voiceReq.AudioStream = bytes.NewReader(make([]byte, 64))
+
+	events, err := houndifyClient.VoiceSearchEvents(voiceReq)
+	assert.NilError(t, err)
+
+	var received []VoiceEvent
+	for event := range events {
+		received = append(received, event)
+	}
+
+	assert.Equal(t, len(received), 5)
+	assert.Equal(t, received[0].Kind, VoicePartialEvent)
+	assert.Equal(t, received[0].Partial.Message, "what")
+	assert.Equal(t, received[1].Kind, VoicePartialEvent)
+	assert.Equal(t, received[1].Partial.Message, "what time")
+	assert.Equal(t, received[2].Kind, VoiceSafeToStopEvent)
+	assert.Equal(t, received[3].Kind, VoicePartialEvent)
+	assert.Equal(t, received[3].Partial.Done, true)
+	assert.Equal(t, received[4].Kind, VoiceResultEvent)
+	assert.Equal(t, received[4].Result, result)
+	assert.NilError(t, received[4].Err)
+}